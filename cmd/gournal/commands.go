@@ -0,0 +1,231 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"sumologic.com/journalctl/journal"
+)
+
+// openReaders creates Reader for every file matching the given patterns
+func openReaders(patterns []string) ([]*journal.Reader, error) {
+	readers := []*journal.Reader{}
+
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, path := range files {
+			reader, err := journal.NewReader(path)
+			if err != nil {
+				return nil, fmt.Errorf("cannot open %s: %w", path, err)
+			}
+			readers = append(readers, reader)
+		}
+	}
+
+	return readers, nil
+}
+
+// patternsOrDefault returns patterns or defaultPatterns if there is no pattern
+func patternsOrDefault(patterns []string, defaultPatterns []string) []string {
+	if len(patterns) == 0 {
+		return defaultPatterns
+	}
+	return patterns
+}
+
+// fieldsCommand prints names of all fields used in the files (journalctl -N)
+func fieldsCommand(patterns []string) error {
+	readers, err := openReaders(patterns)
+	if err != nil {
+		return err
+	}
+
+	fields := []string{}
+	for _, reader := range readers {
+		defer reader.Close()
+
+		readerFields, err := reader.Fields()
+		if err != nil {
+			return fmt.Errorf("cannot read fields of %s: %w", reader.Name(), err)
+		}
+		fields = append(fields, readerFields...)
+	}
+
+	slices.Sort(fields)
+	for _, field := range slices.Compact(fields) {
+		fmt.Println(field)
+	}
+
+	return nil
+}
+
+// fieldValuesCommand prints all distinct values of the field used in the files (journalctl -F)
+func fieldValuesCommand(field string, patterns []string) error {
+	readers, err := openReaders(patterns)
+	if err != nil {
+		return err
+	}
+
+	values := []string{}
+	for _, reader := range readers {
+		defer reader.Close()
+
+		readerValues, err := reader.FieldValues(field)
+		if err != nil {
+			return fmt.Errorf("cannot read values of %s in %s: %w", field, reader.Name(), err)
+		}
+		values = append(values, readerValues...)
+	}
+
+	slices.Sort(values)
+	for _, value := range slices.Compact(values) {
+		fmt.Println(value)
+	}
+
+	return nil
+}
+
+// formatRealtime formats realtime usec in the way used by verify command
+func formatRealtime(usec uint64) string {
+	return time.UnixMicro(int64(usec)).UTC().Format("Mon 2006-01-02 15:04:05 MST")
+}
+
+// verifyCommand verifies consistency of the files (journalctl --verify)
+// seal of the files is verified as well if verification key is given
+func verifyCommand(args []string, defaultPatterns []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	verifyKey := flags.String("verify-key", "", "verification key generated by journalctl --setup-keys")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var key *journal.VerificationKey
+	if *verifyKey != "" {
+		var err error
+		key, err = journal.ParseVerificationKey(*verifyKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	readers, err := openReaders(patternsOrDefault(flags.Args(), defaultPatterns))
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, reader := range readers {
+		defer reader.Close()
+
+		name := reader.Name()
+		if err := verifyFile(reader, key); err != nil {
+			failed = true
+			fmt.Printf("FAIL: %s (%s)\n", name, err)
+		}
+	}
+
+	if failed {
+		return errors.New("verification failed")
+	}
+	return nil
+}
+
+// verifyFile verifies single file and prints the result
+func verifyFile(reader *journal.Reader, key *journal.VerificationKey) error {
+	name := reader.Name()
+
+	err := reader.Verify()
+	corruption := &journal.CorruptionError{}
+	if errors.As(err, &corruption) {
+		fmt.Printf("File corruption detected at %s:%d.\n", name, corruption.Offset)
+	}
+	if err != nil {
+		return err
+	}
+
+	if key == nil {
+		fmt.Printf("PASS: %s\n", name)
+		return nil
+	}
+
+	report, err := reader.VerifySeal(key)
+	if report != nil && report.Tampered {
+		fmt.Printf("File corruption detected at %s:%d-%d.\n", name, report.TamperedFrom, report.TamperedTo)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("PASS: %s\n", name)
+	if report.Tags > 0 {
+		fmt.Printf("=> Validated from %s to %s, final %s entries not sealed.\n",
+			formatRealtime(report.FirstRealtime),
+			formatRealtime(report.LastSealedRealtime),
+			time.Duration(report.LastRealtime-report.LastSealedRealtime)*time.Microsecond)
+	}
+
+	return nil
+}
+
+// importCommand writes entries in Journal Export Format read from the input to the new journal file
+func importCommand(filename string, input io.Reader) error {
+	writer, err := journal.NewWriter(filename, journal.WriterConfig{Compact: true, KeyedHash: true, Compress: true})
+	if err != nil {
+		return err
+	}
+
+	reader := journal.NewExportReader(input)
+	for {
+		log, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err == nil {
+			err = writer.WriteLog(log)
+		}
+		if err != nil {
+			writer.Close()
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+// runCommand runs command given as the first argument
+// defaultPatterns are used if no file is specified for the command
+// it returns false if there is no such command
+func runCommand(args []string, defaultPatterns []string) (bool, error) {
+	if len(args) == 0 {
+		return false, nil
+	}
+
+	switch args[0] {
+	case "fields":
+		return true, fieldsCommand(patternsOrDefault(args[1:], defaultPatterns))
+	case "field-values":
+		if len(args) < 2 {
+			return true, fmt.Errorf("usage: %s field-values FIELD [FILE...]", filepath.Base(os.Args[0]))
+		}
+		return true, fieldValuesCommand(args[1], patternsOrDefault(args[2:], defaultPatterns))
+	case "verify":
+		return true, verifyCommand(args[1:], defaultPatterns)
+	case "import":
+		if len(args) != 2 {
+			return true, fmt.Errorf("usage: %s import FILE < EXPORT", filepath.Base(os.Args[0]))
+		}
+		return true, importCommand(args[1], os.Stdin)
+	default:
+		return false, nil
+	}
+}
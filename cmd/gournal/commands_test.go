@@ -0,0 +1,60 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// captureStdout returns everything written to the standard output by fn
+func captureStdout(t *testing.T, fn func() error) string {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	err = fn()
+	os.Stdout = stdout
+	require.NoError(t, w.Close())
+	require.NoError(t, err)
+
+	return <-output
+}
+
+func TestFieldsCommand(t *testing.T) {
+	directory := t.TempDir()
+	require.NoError(t, importCommand(filepath.Join(directory, "a.journal"), strings.NewReader(
+		"__REALTIME_TIMESTAMP=1\nMESSAGE=first\n_PID=1\n\n"+
+			"__REALTIME_TIMESTAMP=2\nMESSAGE=second\n_PID=2\n\n",
+	)))
+	require.NoError(t, importCommand(filepath.Join(directory, "b.journal"), strings.NewReader(
+		"__REALTIME_TIMESTAMP=3\nMESSAGE=first\n_PID=1\n_COMM=sshd\n\n",
+	)))
+	patterns := []string{filepath.Join(directory, "*.journal")}
+
+	// fields and values used by both files are printed once
+	output := captureStdout(t, func() error { return fieldsCommand(patterns) })
+	assert.Equal(t, "MESSAGE\n_COMM\n_PID\n", output)
+
+	output = captureStdout(t, func() error { return fieldValuesCommand("MESSAGE", patterns) })
+	assert.Equal(t, "first\nsecond\n", output)
+
+	output = captureStdout(t, func() error { return fieldValuesCommand("_UID", patterns) })
+	assert.Equal(t, "", output)
+
+	_, err := runCommand([]string{"field-values"}, patterns)
+	assert.Error(t, err)
+}
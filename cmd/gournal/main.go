@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sumologic.com/journalctl/journal"
+)
+
+func main() {
+	filepaths := []string{
+		"test-data/**.journal",
+	}
+
+	lines := flag.Int("n", -1, "show the last n entries of existing files and follow (negative value shows all entries)")
+	output := flag.String("o", journal.OUTPUT_KEY_VALUE, "output format (keyvalue, export, json, json-pretty, json-sse, json-seq)")
+	showAll := flag.Bool("a", false, "show fields bigger than 4096 bytes in json output formats")
+	priorities := flag.String("p", "", "show entries with the priority or more severe ones, or with the priority in the range (e.g. warning, err..warning)")
+	reverse := flag.Bool("r", false, "show the newest entries first, existing files are not followed")
+	facilities := flag.String("facility", "", "show entries with any of the comma separated syslog facilities (e.g. auth,authpriv)")
+	maxDataSize := flag.Int("max-data-size", journal.DEFAULT_MAX_DATA_SIZE, "maximum size of the decompressed field in bytes, entries with bigger fields are skipped")
+	flag.Parse()
+
+	logWriter, err := journal.NewLogWriter(*output, *showAll, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	ok, err := runCommand(flag.Args(), filepaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if ok {
+		return
+	}
+	// positional arguments are matches of the filter expression, e.g. _SYSTEMD_UNIT=ssh.service + _PID>1000
+	expression, err := journal.ParseFilter(strings.Join(flag.Args(), " "))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	filterChain := journal.FilterChain{
+		FilterChains: []journal.FilterChain{expression},
+		Filters:      []journal.Filter{},
+	}
+
+	if *priorities != "" {
+		priorityFilter, err := journal.NewPriorityFilter(*priorities)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		filterChain.Filters = append(filterChain.Filters, priorityFilter)
+	}
+
+	if *facilities != "" {
+		facilityFilter, err := journal.NewFacilityFilter(strings.Split(*facilities, ",")...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		filterChain.Filters = append(filterChain.Filters, facilityFilter)
+	}
+
+	directoryReader := journal.NewDirectoryReader()
+	directoryReader.Tail = *lines
+	directoryReader.Reverse = *reverse
+	directoryReader.MaxDataSize = *maxDataSize
+	directoryReader.OnError = func(err *journal.ReadError) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	// Read returns the error of Monitor as well
+	go directoryReader.Monitor(context.Background(), filepaths)
+
+	err = directoryReader.Read(filterChain, logWriter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,298 @@
+package journal
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// This implementation base on sd_journal_get_cursor and sd_journal_seek_cursor
+// rel: https://github.com/systemd/systemd/blob/main/src/libsystemd/sd-journal/sd-journal.c
+
+// Definitions of the cursor fields, used in Cursor.Present
+const (
+	CURSOR_SEQNUM_ID = 1 << iota
+	CURSOR_SEQNUM
+	CURSOR_BOOT_ID
+	CURSOR_MONOTONIC
+	CURSOR_REALTIME
+	CURSOR_XOR_HASH
+
+	CURSOR_ALL = CURSOR_SEQNUM_ID | CURSOR_SEQNUM | CURSOR_BOOT_ID | CURSOR_MONOTONIC | CURSOR_REALTIME | CURSOR_XOR_HASH
+)
+
+// Cursor identifies an entry in the same way as cursor of journalctl
+// it is serialized as s=seqnum_id;i=seqnum;b=boot_id;m=monotonic;t=realtime;x=xor_hash
+type Cursor struct {
+	SeqnumID  [16]byte
+	Seqnum    uint64
+	BootID    [16]byte
+	Monotonic uint64
+	Realtime  uint64
+	XorHash   uint64
+
+	// CURSOR_* flags of the fields present in the cursor
+	Present uint8
+}
+
+// ParseCursor parses and validates the cursor
+// all fields are optional, but the cursor has to contain enough of them to find the location
+func ParseCursor(value string) (Cursor, error) {
+	cursor := Cursor{}
+
+	for _, item := range strings.Split(value, ";") {
+		key, itemValue, found := strings.Cut(item, "=")
+		if !found || len(key) != 1 {
+			return Cursor{}, fmt.Errorf("invalid cursor item %q", item)
+		}
+
+		var err error
+		switch key {
+		case "s":
+			cursor.SeqnumID, err = parseCursorID(itemValue)
+			cursor.Present |= CURSOR_SEQNUM_ID
+		case "i":
+			cursor.Seqnum, err = strconv.ParseUint(itemValue, 16, 64)
+			cursor.Present |= CURSOR_SEQNUM
+		case "b":
+			cursor.BootID, err = parseCursorID(itemValue)
+			cursor.Present |= CURSOR_BOOT_ID
+		case "m":
+			cursor.Monotonic, err = strconv.ParseUint(itemValue, 16, 64)
+			cursor.Present |= CURSOR_MONOTONIC
+		case "t":
+			cursor.Realtime, err = strconv.ParseUint(itemValue, 16, 64)
+			cursor.Present |= CURSOR_REALTIME
+		case "x":
+			cursor.XorHash, err = strconv.ParseUint(itemValue, 16, 64)
+			cursor.Present |= CURSOR_XOR_HASH
+		default:
+			// unknown items are ignored for compatibility with newer versions
+			continue
+		}
+		if err != nil {
+			return Cursor{}, fmt.Errorf("invalid cursor item %q: %w", item, err)
+		}
+	}
+
+	if err := cursor.Validate(); err != nil {
+		return Cursor{}, err
+	}
+
+	return cursor, nil
+}
+
+// parseCursorID parses 128-bit id written as 32 hex characters
+func parseCursorID(value string) ([16]byte, error) {
+	id := [16]byte{}
+	if len(value) != 2*len(id) {
+		return id, fmt.Errorf("id has to be %d hex characters", 2*len(id))
+	}
+
+	_, err := hex.Decode(id[:], []byte(value))
+	return id, err
+}
+
+// has returns true if all the fields are present in the cursor
+func (c Cursor) has(fields uint8) bool {
+	return c.Present&fields == fields
+}
+
+// Validate returns error if the cursor doesn't identify a location
+// it requires seqnum with seqnum_id, monotonic with boot_id or realtime
+func (c Cursor) Validate() error {
+	if !c.has(CURSOR_SEQNUM_ID|CURSOR_SEQNUM) && !c.has(CURSOR_BOOT_ID|CURSOR_MONOTONIC) && !c.has(CURSOR_REALTIME) {
+		return errors.New("cursor has to contain seqnum with seqnum_id, monotonic with boot_id or realtime")
+	}
+	return nil
+}
+
+// String returns the cursor in the format used by journalctl
+func (c Cursor) String() string {
+	items := []string{}
+	if c.has(CURSOR_SEQNUM_ID) {
+		items = append(items, fmt.Sprintf("s=%x", c.SeqnumID[:]))
+	}
+	if c.has(CURSOR_SEQNUM) {
+		items = append(items, fmt.Sprintf("i=%x", c.Seqnum))
+	}
+	if c.has(CURSOR_BOOT_ID) {
+		items = append(items, fmt.Sprintf("b=%x", c.BootID[:]))
+	}
+	if c.has(CURSOR_MONOTONIC) {
+		items = append(items, fmt.Sprintf("m=%x", c.Monotonic))
+	}
+	if c.has(CURSOR_REALTIME) {
+		items = append(items, fmt.Sprintf("t=%x", c.Realtime))
+	}
+	if c.has(CURSOR_XOR_HASH) {
+		items = append(items, fmt.Sprintf("x=%x", c.XorHash))
+	}
+
+	return strings.Join(items, ";")
+}
+
+// matches returns true if all fields present in the cursor are equal to the fields of the entry
+// seqnumID is seqnum_id of the file containing the entry, seqnum is compared only if it is the same
+// as the one in the cursor, because the same entry can have different seqnum in another file
+func (c Cursor) matches(entry *Entry, seqnumID [16]byte) bool {
+	sameSequence := !c.has(CURSOR_SEQNUM_ID) || c.SeqnumID == seqnumID
+	return (!sameSequence || !c.has(CURSOR_SEQNUM) || c.Seqnum == entry.seqnum) &&
+		(!c.has(CURSOR_BOOT_ID) || c.BootID == entry.boot_id) &&
+		(!c.has(CURSOR_MONOTONIC) || c.Monotonic == entry.monotonic) &&
+		(!c.has(CURSOR_REALTIME) || c.Realtime == entry.realtime) &&
+		(!c.has(CURSOR_XOR_HASH) || c.XorHash == entry.xor_hash)
+}
+
+// getCursor returns cursor of the entry
+func (r *Reader) getCursor(entry *Entry) Cursor {
+	return entryCursor(entry, r.getHeader().seqnum_id)
+}
+
+// entryCursor returns cursor of the entry stored in the file with the given seqnum_id
+func entryCursor(entry *Entry, seqnumID [16]byte) Cursor {
+	return Cursor{
+		SeqnumID:  seqnumID,
+		Seqnum:    entry.seqnum,
+		BootID:    entry.boot_id,
+		Monotonic: entry.monotonic,
+		Realtime:  entry.realtime,
+		XorHash:   entry.xor_hash,
+		Present:   CURSOR_ALL,
+	}
+}
+
+// cursorLocation returns predicates used to find location of the cursor in the file
+// before returns true for entries before the location and at for entries at the location
+// seqnum is used if the cursor comes from the same sequence of entries, monotonic time of the boot
+// otherwise and realtime if the boot is not in the file, so the location is found even if the entry
+// has been removed or the cursor comes from another file
+func (r *Reader) cursorLocation(cursor Cursor) (before func(*Entry) bool, at func(*Entry) bool, err error) {
+	if cursor.has(CURSOR_SEQNUM_ID|CURSOR_SEQNUM) && cursor.SeqnumID == r.getHeader().seqnum_id {
+		before = func(entry *Entry) bool { return entry.seqnum < cursor.Seqnum }
+		at = func(entry *Entry) bool { return entry.seqnum == cursor.Seqnum }
+		return before, at, nil
+	}
+
+	if cursor.has(CURSOR_BOOT_ID | CURSOR_MONOTONIC) {
+		data, err := r.findData([]byte("_BOOT_ID=" + hex.EncodeToString(cursor.BootID[:])))
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if data != nil {
+			// monotonic time is growing only within the boot, so the first entry of the boot
+			// at the location is found and seqnum is used to seek in the whole file
+			entry, last, err := r.bisectDataEntries(data, func(entry *Entry) bool {
+				return entry.monotonic < cursor.Monotonic
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+
+			seqnum := uint64(0)
+			switch {
+			case entry != nil:
+				seqnum = entry.seqnum
+			case last != nil:
+				seqnum = last.seqnum + 1
+			}
+
+			if entry != nil || last != nil {
+				before = func(entry *Entry) bool { return entry.seqnum < seqnum }
+				at = func(entry *Entry) bool {
+					return entry.boot_id == cursor.BootID && entry.monotonic == cursor.Monotonic
+				}
+				return before, at, nil
+			}
+		}
+	}
+
+	if cursor.has(CURSOR_REALTIME) {
+		before = func(entry *Entry) bool { return entry.realtime < cursor.Realtime }
+		at = func(entry *Entry) bool { return entry.realtime == cursor.Realtime }
+		return before, at, nil
+	}
+
+	return nil, nil, fmt.Errorf("cannot find location of cursor %s", cursor)
+}
+
+// SeekCursor sets position right before the entry with the given cursor
+// if there is no such entry, position is set right before the first entry at or after the cursor location
+func (r *Reader) SeekCursor(cursor Cursor) error {
+	err := r.loadHeader()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err = r.seekCursorLocked(cursor)
+	return err
+}
+
+// SeekAfterCursor sets position right after the entry with the given cursor
+// if there is no such entry, position is set right before the first entry at or after the cursor location
+func (r *Reader) SeekAfterCursor(cursor Cursor) error {
+	err := r.loadHeader()
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found, err := r.seekCursorLocked(cursor)
+	if err != nil || !found {
+		return err
+	}
+
+	_, err = r.getNextEntryLocked()
+	return err
+}
+
+// seekCursorLocked sets position right before the entry with the given cursor
+// entries at the cursor location are checked one by one, as more of them can share the timestamp
+// it returns false if there is no such entry and position is right before the first entry after the location
+// lock has to be held by the caller
+func (r *Reader) seekCursorLocked(cursor Cursor) (bool, error) {
+	if err := cursor.Validate(); err != nil {
+		return false, err
+	}
+
+	before, at, err := r.cursorLocation(cursor)
+	if err != nil {
+		return false, err
+	}
+
+	err = r.seekEntryLocked(before)
+	if err != nil {
+		return false, err
+	}
+
+	seqnumID := r.getHeader().seqnum_id
+	arrayOffset, itemOffset := r.nextArrayOffset, r.nextItemOffset
+	for {
+		nextArrayOffset, nextItemOffset := r.nextArrayOffset, r.nextItemOffset
+
+		entry, err := r.getNextEntryLocked()
+		if err != nil {
+			return false, err
+		}
+
+		if entry == nil || !at(entry) {
+			break
+		}
+
+		if cursor.matches(entry, seqnumID) {
+			r.nextArrayOffset, r.nextItemOffset = nextArrayOffset, nextItemOffset
+			return true, nil
+		}
+	}
+
+	r.nextArrayOffset, r.nextItemOffset = arrayOffset, itemOffset
+	return false, nil
+}
@@ -0,0 +1,192 @@
+package journal
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCursor(t *testing.T) {
+	testCases := []struct {
+		name          string
+		cursor        string
+		expected      Cursor
+		expectedError bool
+	}{
+		{
+			name:   "full",
+			cursor: "s=69e0bc24292040569344cea3ad97204c;i=810;b=6b84ae3ed1114c0b900c8c464e64a015;m=155e8d7;t=616c4f6c535b6;x=23a3cd7d2742e8c3",
+			expected: Cursor{
+				SeqnumID:  [16]byte{0x69, 0xe0, 0xbc, 0x24, 0x29, 0x20, 0x40, 0x56, 0x93, 0x44, 0xce, 0xa3, 0xad, 0x97, 0x20, 0x4c},
+				Seqnum:    0x810,
+				BootID:    [16]byte{0x6b, 0x84, 0xae, 0x3e, 0xd1, 0x11, 0x4c, 0x0b, 0x90, 0x0c, 0x8c, 0x46, 0x4e, 0x64, 0xa0, 0x15},
+				Monotonic: 0x155e8d7,
+				Realtime:  0x616c4f6c535b6,
+				XorHash:   0x23a3cd7d2742e8c3,
+				Present:   CURSOR_ALL,
+			},
+		},
+		{
+			name:   "realtime only",
+			cursor: "t=616c4f6c535b6",
+			expected: Cursor{
+				Realtime: 0x616c4f6c535b6,
+				Present:  CURSOR_REALTIME,
+			},
+		},
+		{
+			name:   "unknown item",
+			cursor: "t=1;y=abc",
+			expected: Cursor{
+				Realtime: 1,
+				Present:  CURSOR_REALTIME,
+			},
+		},
+		{
+			name:          "seqnum without seqnum_id",
+			cursor:        "i=810;m=155e8d7",
+			expectedError: true,
+		},
+		{
+			name:          "short id",
+			cursor:        "s=69e0bc;i=810",
+			expectedError: true,
+		},
+		{
+			name:          "invalid number",
+			cursor:        "t=xyz",
+			expectedError: true,
+		},
+		{
+			name:          "item without value",
+			cursor:        "t",
+			expectedError: true,
+		},
+		{
+			name:          "empty",
+			cursor:        "",
+			expectedError: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := ParseCursor(tt.cursor)
+			if tt.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, cursor)
+		})
+	}
+}
+
+func TestCursorString(t *testing.T) {
+	value := "s=69e0bc24292040569344cea3ad97204c;i=810;b=6b84ae3ed1114c0b900c8c464e64a015;m=155e8d7;t=616c4f6c535b6;x=23a3cd7d2742e8c3"
+	cursor, err := ParseCursor(value)
+	require.NoError(t, err)
+	assert.Equal(t, value, cursor.String())
+
+	cursor = Cursor{BootID: testBootID, Monotonic: 0x10, Present: CURSOR_BOOT_ID | CURSOR_MONOTONIC}
+	assert.Equal(t, "b=0102030405060708090a0b0c0d0e0f10;m=10", cursor.String())
+}
+
+// readCursors returns cursors of all entries of the file
+func readCursors(t *testing.T, reader *Reader) []Cursor {
+	cursors := []Cursor{}
+	for {
+		log, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return cursors
+		}
+		require.NoError(t, err)
+
+		cursor, err := ParseCursor(log.Cursor())
+		require.NoError(t, err)
+		cursors = append(cursors, cursor)
+	}
+}
+
+func TestReaderSeekCursor(t *testing.T) {
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{Compact: true}, 10))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	cursors := readCursors(t, reader)
+	require.Len(t, cursors, 10)
+
+	// cursor of another entry at the same location
+	different := cursors[4]
+	different.XorHash++
+
+	otherFile := cursors[4]
+	otherFile.SeqnumID[0]++
+
+	otherBoot := cursors[4]
+	otherBoot.SeqnumID[0]++
+	otherBoot.BootID[0]++
+
+	testCases := []struct {
+		name          string
+		cursor        Cursor
+		expected      []string
+		expectedAfter []string
+	}{
+		{
+			name:          "exact",
+			cursor:        cursors[4],
+			expected:      testMessages(4, 5, 6, 7, 8, 9),
+			expectedAfter: testMessages(5, 6, 7, 8, 9),
+		},
+		{
+			name:          "different entry at the location",
+			cursor:        different,
+			expected:      testMessages(4, 5, 6, 7, 8, 9),
+			expectedAfter: testMessages(4, 5, 6, 7, 8, 9),
+		},
+		{
+			name:          "seqnum_id mismatch uses monotonic time",
+			cursor:        otherFile,
+			expected:      testMessages(4, 5, 6, 7, 8, 9),
+			expectedAfter: testMessages(5, 6, 7, 8, 9),
+		},
+		{
+			name:          "unknown boot uses realtime",
+			cursor:        otherBoot,
+			expected:      testMessages(4, 5, 6, 7, 8, 9),
+			expectedAfter: testMessages(4, 5, 6, 7, 8, 9),
+		},
+		{
+			name:          "vacuumed entry",
+			cursor:        Cursor{Realtime: cursors[4].Realtime - 1, Present: CURSOR_REALTIME},
+			expected:      testMessages(4, 5, 6, 7, 8, 9),
+			expectedAfter: testMessages(4, 5, 6, 7, 8, 9),
+		},
+		{
+			name:          "monotonic after the last entry",
+			cursor:        Cursor{BootID: testBootID, Monotonic: cursors[9].Monotonic + 1, Present: CURSOR_BOOT_ID | CURSOR_MONOTONIC},
+			expected:      testMessages(),
+			expectedAfter: testMessages(),
+		},
+		{
+			name:          "seqnum before the first entry",
+			cursor:        Cursor{SeqnumID: cursors[0].SeqnumID, Present: CURSOR_SEQNUM_ID | CURSOR_SEQNUM},
+			expected:      testMessages(0, 1, 2, 3, 4, 5, 6, 7, 8, 9),
+			expectedAfter: testMessages(0, 1, 2, 3, 4, 5, 6, 7, 8, 9),
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, reader.SeekCursor(tt.cursor))
+			assert.Equal(t, tt.expected, readMessages(t, reader.Next))
+
+			require.NoError(t, reader.SeekAfterCursor(tt.cursor))
+			assert.Equal(t, tt.expectedAfter, readMessages(t, reader.Next))
+		})
+	}
+
+	assert.Error(t, reader.SeekCursor(Cursor{}))
+}
@@ -0,0 +1,407 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Definitions of the file lifecycle events reported by DirectoryReader
+const (
+	// file has been found and it is read
+	FILE_ADDED = iota
+	// file has been renamed, e.g. system.journal to system@...journal, reading continues
+	FILE_ROTATED
+	// file has been archived and all its entries have been read, so it is closed
+	FILE_DRAINED
+	// file has been removed while it was read, so it is closed
+	FILE_DELETED
+	// file cannot be read because of the error and it is skipped according to the error policy
+	FILE_FAILED
+)
+
+// FileEvent describes change of the file lifecycle
+type FileEvent struct {
+	Type   int
+	Path   string
+	FileID [16]byte
+}
+
+// directoryFile is journal file known to DirectoryReader
+type directoryFile struct {
+	path string
+
+	// reader is nil once the file has been drained or skipped because of the error
+	reader *Reader
+}
+
+// openRetry is the file which failed to open and it is opened again by the next scan
+type openRetry struct {
+	// number of failed attempts in a row
+	retries int
+
+	// file has been found on start, so tail is applied to it
+	initial bool
+}
+
+// DirectoryReader follows all journal files matching the patterns
+// files are discovered by Monitor and their entries are delivered by Read in chronological order
+// files are identified by file_id, so renamed files are read from the same position,
+// archived files are closed once they have been read and removed files are closed immediately
+type DirectoryReader struct {
+	// files by file_id, only Monitor uses it
+	files  map[[16]byte]*directoryFile
+	merged *MergedReader
+
+	// watcher wakes up Monitor and Read once files change
+	watcher *watcher
+
+	// number of the lifecycle events, used to find out if scan changed anything
+	events int
+
+	// files which failed to open by path, they are opened again by the next scan, only Monitor uses it
+	retries map[string]*openRetry
+
+	// done is closed once Monitor returns and err is the error it returned
+	done chan struct{}
+	err  error
+
+	// scanned is closed once Monitor has found the files existing on start
+	scanned chan struct{}
+
+	// Tail is number of the last entries to read from files existing on start
+	// negative value means that files are read from the beginning
+	// in reverse it is the number of the newest entries read by Read
+	Tail int
+
+	// Reverse reads entries of the files from the newest to the oldest one
+	// Read returns once all entries of the files existing on start have been read, they are not followed
+	Reverse bool
+
+	// OnFileEvent is called by Monitor on every change of the file lifecycle if set
+	OnFileEvent func(event FileEvent)
+
+	// ErrorPolicies decide how errors of reading are handled, nil means DefaultErrorPolicies
+	ErrorPolicies ErrorPolicies
+
+	// OnError is called by Monitor and Read on every error of reading if set
+	// it is called from both goroutines, so it has to be safe for concurrent use
+	OnError func(err *ReadError)
+
+	// MaxDataSize is maximum size of decompressed Data payload of the files, bigger payloads fail to read
+	MaxDataSize int
+}
+
+// NewDirectoryReader returns DirectoryReader reading all entries of the files
+func NewDirectoryReader() DirectoryReader {
+	return DirectoryReader{
+		files:       map[[16]byte]*directoryFile{},
+		merged:      NewMergedReader(),
+		watcher:     newWatcher(),
+		retries:     map[string]*openRetry{},
+		done:        make(chan struct{}),
+		scanned:     make(chan struct{}),
+		Tail:        -1,
+		MaxDataSize: DEFAULT_MAX_DATA_SIZE,
+	}
+}
+
+// Read writes logs matching the filter chain using the output
+// it blocks until the output returns an error, reading is aborted because of the error policy or Monitor returns
+// once all entries are read, it waits for modification of the files or polls them if it is not notified
+func (dr *DirectoryReader) Read(filterChain FilterChain, output LogWriter) error {
+	dr.merged.SetFilter(&filterChain)
+	dr.merged.SetErrorHandler(dr.ErrorPolicies, dr.OnError)
+	if dr.Reverse {
+		return dr.readReverse(output)
+	}
+	poll := newAdaptivePoll()
+
+	for {
+		log, err := dr.merged.Next()
+		if errors.Is(err, io.EOF) {
+			select {
+			case <-dr.done:
+				return dr.err
+			case <-dr.watcher.modified:
+			case <-time.After(dr.watcher.pollInterval(&poll)):
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		poll.reset()
+
+		if err := output.Write(log); err != nil {
+			return err
+		}
+	}
+}
+
+// readReverse writes logs of the files existing on start from the newest one
+// it returns once all of them or Tail of them have been written
+func (dr *DirectoryReader) readReverse(output LogWriter) error {
+	if err := dr.merged.SetReverse(true); err != nil {
+		return err
+	}
+
+	// all files have to be known before the newest entry can be found
+	select {
+	case <-dr.done:
+		return dr.err
+	case <-dr.scanned:
+	}
+
+	written := 0
+	for dr.Tail < 0 || written < dr.Tail {
+		log, err := dr.merged.Next()
+		if errors.Is(err, io.EOF) {
+			if !dr.merged.waiting() {
+				return nil
+			}
+			// files failed with transient error are read again
+			select {
+			case <-dr.done:
+				return dr.err
+			case <-time.After(RETRY_INTERVAL):
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := output.Write(log); err != nil {
+			return err
+		}
+		written++
+	}
+
+	return nil
+}
+
+// Monitor looks for new files matching the include patterns and reads them until ctx is done
+// directories are watched using inotify and polled if it is not available
+// it returns error if any pattern is invalid or reading is aborted because of the error policy
+// all files are closed once it returns and Read returns the same error
+func (dr *DirectoryReader) Monitor(ctx context.Context, include []string) error {
+	defer close(dr.done)
+	defer dr.merged.Close()
+	defer dr.watcher.close()
+
+	for _, pattern := range include {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			dr.err = fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			return dr.err
+		}
+	}
+
+	// files created after start are always read from the beginning
+	initial := true
+	poll := newAdaptivePoll()
+
+	for {
+		dr.watchDirectories(include)
+		changed, err := dr.scan(include, initial)
+		if err != nil {
+			dr.err = err
+			return err
+		}
+		if changed {
+			// new files can contain entries already, so Read is woken up
+			notify(dr.watcher.modified)
+			poll.reset()
+		}
+		if initial {
+			close(dr.scanned)
+		}
+		initial = false
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-dr.watcher.changed:
+		case <-time.After(dr.watcher.pollInterval(&poll)):
+		}
+	}
+}
+
+// watchDirectories watches directories, which can contain files matching the include patterns
+func (dr *DirectoryReader) watchDirectories(include []string) {
+	for _, pattern := range include {
+		// invalid pattern is reported by Monitor
+		directories, _ := filepath.Glob(filepath.Dir(pattern))
+		for _, directory := range directories {
+			// directory which cannot be watched is polled
+			dr.watcher.watch(directory)
+		}
+	}
+}
+
+// event reports change of the file lifecycle
+func (dr *DirectoryReader) event(eventType int, path string, fileID [16]byte) {
+	dr.events++
+	if dr.OnFileEvent != nil {
+		dr.OnFileEvent(FileEvent{Type: eventType, Path: path, FileID: fileID})
+	}
+}
+
+// scan adds new files matching the include patterns and updates the known ones
+// tail is applied to the new files only if initial is true
+// it returns true if lifecycle of any file changed and error if reading has to be aborted
+func (dr *DirectoryReader) scan(include []string, initial bool) (bool, error) {
+	events := dr.events
+	seen := map[[16]byte]bool{}
+	found := map[string]bool{}
+
+	for _, pattern := range include {
+		// patterns are validated by Monitor
+		paths, _ := filepath.Glob(pattern)
+
+		for _, path := range paths {
+			found[path] = true
+			fileID, ok, err := dr.open(path, initial)
+			if err != nil {
+				return dr.events != events, err
+			}
+			if ok {
+				seen[fileID] = true
+			}
+		}
+	}
+
+	// archived files don't grow, so they can be closed once all entries have been read
+	for _, reader := range dr.merged.removeDrained() {
+		fileID := reader.getHeader().file_id
+		dr.files[fileID].reader = nil
+		reader.Close()
+		dr.event(FILE_DRAINED, dr.files[fileID].path, fileID)
+	}
+
+	// failed files are remembered as drained ones, so they are not read again
+	for _, reader := range dr.merged.removeFailed() {
+		fileID := reader.getHeader().file_id
+		dr.files[fileID].reader = nil
+		reader.Close()
+		dr.event(FILE_FAILED, dr.files[fileID].path, fileID)
+	}
+
+	for fileID, file := range dr.files {
+		if file.reader == nil {
+			// drained file is remembered until it is removed, so it is not read again
+			if !seen[fileID] {
+				delete(dr.files, fileID)
+			}
+			continue
+		}
+
+		deleted, err := file.reader.isDeleted()
+		if err != nil || !deleted {
+			continue
+		}
+
+		dr.merged.Remove(file.reader)
+		file.reader.Close()
+		delete(dr.files, fileID)
+		dr.event(FILE_DELETED, file.path, fileID)
+	}
+
+	// files which are not going to be retried are forgotten
+	for path := range dr.retries {
+		if !found[path] {
+			delete(dr.retries, path)
+		}
+	}
+
+	return dr.events != events, nil
+}
+
+// open starts reading the file unless it is already known
+// it returns file_id of the file and false if the file is not known, e.g. it cannot be read yet
+// errors of reading the file are handled according to the error policies, error is returned for POLICY_ABORT only
+func (dr *DirectoryReader) open(path string, initial bool) ([16]byte, bool, error) {
+	fileID := [16]byte{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fileID, false, nil
+	}
+
+	_, err = file.ReadAt(fileID[:], 24)
+	if err != nil {
+		file.Close()
+		return fileID, false, nil
+	}
+
+	if known, ok := dr.files[fileID]; ok {
+		file.Close()
+		if known.path != path {
+			known.path = path
+			dr.event(FILE_ROTATED, path, fileID)
+		}
+		return fileID, true, nil
+	}
+
+	// file which failed to open on start is still read as if it has been found on start
+	if retry, ok := dr.retries[path]; ok {
+		initial = retry.initial
+	}
+
+	reader, err := newReaderFromPointer(file)
+	if err != nil {
+		file.Close()
+		return dr.failed(path, fileID, err, initial)
+	}
+	reader.MaxDataSize = dr.MaxDataSize
+
+	switch {
+	case dr.Reverse:
+		err = reader.SeekTail()
+	case initial && dr.Tail >= 0:
+		err = reader.seekTailEntries(dr.Tail)
+	}
+	if err != nil {
+		reader.Close()
+		return dr.failed(path, fileID, err, initial)
+	}
+	delete(dr.retries, path)
+
+	dr.files[fileID] = &directoryFile{path: path, reader: reader}
+	dr.merged.Add(reader)
+	dr.event(FILE_ADDED, path, fileID)
+
+	return fileID, true, nil
+}
+
+// failed handles the error of opening the file according to the error policies
+// file is opened again by the next scan if it is retried, otherwise it is remembered as failed
+// and it is not read until it is removed
+// it returns the same values as open
+func (dr *DirectoryReader) failed(path string, fileID [16]byte, err error, initial bool) ([16]byte, bool, error) {
+	retry, ok := dr.retries[path]
+	if !ok {
+		retry = &openRetry{initial: initial}
+	}
+
+	// there is no position in the file yet, so no entry can be skipped
+	handler := errorHandler{policies: dr.ErrorPolicies, onError: dr.OnError}
+	policy, readError := handler.handle(path, err, retry.retries, nil)
+	switch policy {
+	case POLICY_RETRY:
+		retry.retries++
+		dr.retries[path] = retry
+		return fileID, false, nil
+	case POLICY_ABORT:
+		return fileID, false, readError
+	}
+
+	delete(dr.retries, path)
+	dr.files[fileID] = &directoryFile{path: path}
+	dr.event(FILE_FAILED, path, fileID)
+	return fileID, true, nil
+}
@@ -0,0 +1,218 @@
+package journal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// archiveJournal marks the file archived and renames it in the same way as journald does on rotation
+func archiveJournal(t *testing.T, path string, archived string) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	// state is stored right after signature and flags
+	_, err = file.WriteAt([]byte{STATE_ARCHIVED}, 16)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	require.NoError(t, os.Rename(path, archived))
+}
+
+func TestDirectoryReaderLifecycle(t *testing.T) {
+	directory := t.TempDir()
+	include := []string{filepath.Join(directory, "*.journal")}
+	active := filepath.Join(directory, "system.journal")
+	archived := filepath.Join(directory, "system@0001.journal")
+
+	events := []FileEvent{}
+	dr := NewDirectoryReader()
+	dr.OnFileEvent = func(event FileEvent) {
+		event.FileID = [16]byte{}
+		events = append(events, event)
+	}
+
+	writer, err := NewWriter(active, WriterConfig{Compact: true})
+	require.NoError(t, err)
+	writeMessages(t, writer, []uint64{1, 2}, []string{"first", "second"})
+
+	dr.scan(include, true)
+	assert.Equal(t, []FileEvent{{Type: FILE_ADDED, Path: active}}, events)
+	assert.Equal(t, []string{"first", "second"}, readMessages(t, dr.merged.Next))
+
+	// file is rotated, so the position is kept and the new file is read from the beginning
+	writeMessages(t, writer, []uint64{3}, []string{"third"})
+	require.NoError(t, writer.Close())
+	archiveJournal(t, active, archived)
+
+	writer, err = NewWriter(active, WriterConfig{Compact: true})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{4}, []string{"fourth"})
+
+	events = events[:0]
+	dr.scan(include, false)
+	assert.ElementsMatch(t, []FileEvent{{Type: FILE_ROTATED, Path: archived}, {Type: FILE_ADDED, Path: active}}, events)
+	assert.Equal(t, []string{"third", "fourth"}, readMessages(t, dr.merged.Next))
+
+	// archived file has been read, so it is closed, but not read again
+	events = events[:0]
+	dr.scan(include, false)
+	assert.Equal(t, []FileEvent{{Type: FILE_DRAINED, Path: archived}}, events)
+	assert.Len(t, dr.files, 2)
+	assert.Len(t, dr.merged.heads, 1)
+
+	events = events[:0]
+	dr.scan(include, false)
+	assert.Empty(t, events)
+	assert.Empty(t, readMessages(t, dr.merged.Next))
+
+	// archived file is vacuumed and the active one is removed while it is read
+	require.NoError(t, os.Remove(archived))
+	require.NoError(t, os.Remove(active))
+
+	events = events[:0]
+	dr.scan(include, false)
+	assert.Equal(t, []FileEvent{{Type: FILE_DELETED, Path: active}}, events)
+	assert.Empty(t, dr.files)
+	assert.Empty(t, dr.merged.heads)
+}
+
+func TestDirectoryReaderTail(t *testing.T) {
+	directory := t.TempDir()
+	include := []string{filepath.Join(directory, "*.journal")}
+
+	writer, err := NewWriter(filepath.Join(directory, "a.journal"), WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{1, 2, 3}, []string{"a1", "a2", "a3"})
+
+	dr := NewDirectoryReader()
+	dr.Tail = 1
+	dr.scan(include, true)
+	assert.Equal(t, []string{"a3"}, readMessages(t, dr.merged.Next))
+
+	// files created later are read from the beginning
+	writer, err = NewWriter(filepath.Join(directory, "b.journal"), WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{4, 5}, []string{"b4", "b5"})
+
+	dr.scan(include, false)
+	assert.Equal(t, []string{"b4", "b5"}, readMessages(t, dr.merged.Next))
+}
+
+func TestDirectoryReaderMaxDataSize(t *testing.T) {
+	path := writeTestJournal(t, WriterConfig{Compress: true}, 12)
+
+	// entries with BIG field exceeding the limit are skipped
+	dr := NewDirectoryReader()
+	dr.MaxDataSize = 1000
+	dr.scan([]string{path}, true)
+	assert.Equal(t, testMessages(1, 2, 3, 4, 5, 6, 7, 8, 9, 11), readMessages(t, dr.merged.Next))
+}
+
+func TestDirectoryReaderReverse(t *testing.T) {
+	directory := t.TempDir()
+	include := []string{filepath.Join(directory, "*.journal")}
+
+	writer, err := NewWriter(filepath.Join(directory, "a.journal"), WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{1, 3, 5}, []string{"a1", "a3", "a5"})
+
+	writer, err = NewWriter(filepath.Join(directory, "b.journal"), WriterConfig{Compact: true})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{2, 4}, []string{"b2", "b4"})
+
+	testCases := []struct {
+		name     string
+		tail     int
+		expected []string
+	}{
+		{
+			name:     "all entries",
+			tail:     -1,
+			expected: []string{"a5", "b4", "a3", "b2", "a1"},
+		},
+		{
+			name:     "newest entries",
+			tail:     2,
+			expected: []string{"a5", "b4"},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			dr := NewDirectoryReader()
+			dr.Reverse = true
+			dr.Tail = tt.tail
+			go dr.Monitor(ctx, include)
+
+			// Read returns once all entries have been read instead of following the files
+			messages := []string{}
+			err := dr.Read(FilterChain{}, logWriterFunc(func(log Log) error {
+				message, _ := log.Get("MESSAGE")
+				messages = append(messages, message)
+				return nil
+			}))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, messages)
+		})
+	}
+}
+
+func TestDirectoryReaderTailOfFiles(t *testing.T) {
+	directory := t.TempDir()
+	include := []string{filepath.Join(directory, "*.journal")}
+
+	writer, err := NewWriter(filepath.Join(directory, "a.journal"), WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{1, 3, 5}, []string{"a1", "a3", "a5"})
+
+	// file written by older journald is positioned by walking the chain of entry arrays
+	writer, err = NewWriter(filepath.Join(directory, "b.journal"), WriterConfig{HeaderSize: 240})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{2, 4}, []string{"b2", "b4"})
+
+	testCases := []struct {
+		name     string
+		tail     int
+		expected []string
+	}{
+		{
+			name:     "no entries",
+			tail:     0,
+			expected: []string{},
+		},
+		{
+			name:     "last entries of every file",
+			tail:     2,
+			expected: []string{"b2", "a3", "b4", "a5"},
+		},
+		{
+			name:     "more entries than files contain",
+			tail:     10,
+			expected: []string{"a1", "b2", "a3", "b4", "a5"},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			dr := NewDirectoryReader()
+			dr.Tail = tt.tail
+			_, err := dr.scan(include, true)
+			require.NoError(t, err)
+			defer dr.merged.Close()
+
+			assert.Equal(t, tt.expected, readMessages(t, dr.merged.Next))
+		})
+	}
+}
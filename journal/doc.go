@@ -0,0 +1,53 @@
+// Package journal reads and writes systemd journal files.
+//
+// This implementation base on https://systemd.io/JOURNAL_FILE_FORMAT/
+//
+// Single file is read using Reader:
+//
+//	reader, err := journal.NewReader("/var/log/journal/<machine-id>/system.journal")
+//	if err != nil {
+//		return err
+//	}
+//	defer reader.Close()
+//
+//	reader.SetFilter(&journal.FilterChain{
+//		Filters: []journal.Filter{{Name: "_SYSTEMD_UNIT", Matches: []string{"ssh.service"}, Keep: true}},
+//	})
+//	for {
+//		log, err := reader.Next()
+//		if errors.Is(err, io.EOF) {
+//			break
+//		}
+//		if err != nil {
+//			return err
+//		}
+//		message, _ := log.Get("MESSAGE")
+//		fmt.Println(log.Cursor(), message)
+//	}
+//
+// Filters with Keep set to false exclude the matching entries. Operator of the filter compares
+// values for equality by default, FILTER_PREFIX, FILTER_SUFFIX, FILTER_GLOB, FILTER_REGEX,
+// FILTER_EXISTS, FILTER_ABSENT and numeric FILTER_LESS, FILTER_GREATER etc. are available as well.
+// Filters are combined by FilterChain with AND or OR and Validate reports invalid ones.
+// NewPriorityFilter and NewFacilityFilter return filters of PRIORITY and SYSLOG_FACILITY
+// accepting names and numbers the same way as journalctl -p and --facility do.
+// ParseFilter builds FilterChain from the text expression, e.g. "_SYSTEMD_UNIT=ssh.service + _PID>1000",
+// and reports SyntaxError with column of the invalid part.
+//
+// Position can be changed with SeekHead, SeekTail, SeekRealtime, SeekSeqnum, SeekMonotonic,
+// SeekCursor and SeekAfterCursor. Cursors are parsed by ParseCursor and the location is found
+// even if the entry has been removed. Previous reads the entries backward.
+//
+// MergedReader reads many files in chronological order, the same way as journalctl does,
+// and DirectoryReader follows all files matching the patterns, including the ones created later.
+// Both of them read from the newest entry with SetReverse and Reverse, the same way as journalctl -r.
+// Rotated files are read from the same position and files are closed once they are archived
+// and read completely or removed. New files and entries are noticed with inotify on Linux
+// and polling with adaptive interval is used if inotify is not available.
+// Errors of reading are reported as ReadError with path and offset of the file and handled
+// according to ErrorPolicies, so transient errors are retried, corrupted entries are skipped
+// and files using unsupported features are skipped by default.
+// Writer creates new journal files, sealed with the verification key if WriterConfig.SealKey is set,
+// and VerifySeal checks the seal of the file. ExportReader, ExportWriter and NewLogWriter
+// handle Journal Export Format and json formats of journalctl.
+package journal
@@ -0,0 +1,146 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"syscall"
+)
+
+// Definitions of the error classes
+const (
+	// reading failed, but it can succeed later, e.g. the file is being written or I/O failed
+	ERROR_TRANSIENT = iota
+	// object of the file is inconsistent or it cannot be decoded
+	ERROR_CORRUPT
+	// file uses a feature which is not implemented
+	ERROR_UNSUPPORTED
+)
+
+// Definitions of the error policies
+const (
+	// read the same entry again later
+	POLICY_RETRY = iota
+	// skip the entry and continue with the next one
+	POLICY_SKIP_ENTRY
+	// stop reading the file and continue with the other ones
+	POLICY_SKIP_FILE
+	// stop reading and return the error
+	POLICY_ABORT
+)
+
+const (
+	// number of retries of transient errors in a row, the file is skipped once it is exceeded
+	MAX_RETRIES = 10
+
+	// minimal time between retries of the file
+	RETRY_INTERVAL = MAX_POLL_INTERVAL
+)
+
+// ReadError describes error of reading the journal file
+type ReadError struct {
+	Path string
+	// offset of the object which cannot be read, 0 if the error is not related to any object
+	Offset uint64
+	// ERROR_* class of the error
+	Class int
+	Err   error
+}
+
+// Error implements error interface
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("%s at %d: %v", e.Path, e.Offset, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// newReadError returns ReadError for the error of reading the file at the given offset
+// errors which are ReadError already are returned as they are and offset of CorruptionError takes precedence
+func newReadError(path string, offset uint64, err error) *ReadError {
+	var readError *ReadError
+	if errors.As(err, &readError) {
+		return readError
+	}
+
+	var corruption *CorruptionError
+	if errors.As(err, &corruption) {
+		offset = corruption.Offset
+	}
+
+	return &ReadError{
+		Path:   path,
+		Offset: offset,
+		Class:  classifyError(err),
+		Err:    err,
+	}
+}
+
+// classifyError returns ERROR_* class of the error
+// errors not recognized as transient or unsupported are considered corruption of the file
+func classifyError(err error) int {
+	var pathError *fs.PathError
+	var errno syscall.Errno
+
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		return ERROR_UNSUPPORTED
+	case errors.Is(err, errOutOfFile), errors.Is(err, io.ErrUnexpectedEOF), errors.As(err, &pathError), errors.As(err, &errno):
+		return ERROR_TRANSIENT
+	default:
+		return ERROR_CORRUPT
+	}
+}
+
+// ErrorPolicies maps ERROR_* classes to POLICY_* policies
+// classes missing in the map use DefaultErrorPolicies
+type ErrorPolicies map[int]int
+
+// DefaultErrorPolicies returns policies retrying transient errors, skipping corrupted entries
+// and files using unsupported features
+func DefaultErrorPolicies() ErrorPolicies {
+	return ErrorPolicies{
+		ERROR_TRANSIENT:   POLICY_RETRY,
+		ERROR_CORRUPT:     POLICY_SKIP_ENTRY,
+		ERROR_UNSUPPORTED: POLICY_SKIP_FILE,
+	}
+}
+
+// policy returns policy for the error class
+func (p ErrorPolicies) policy(class int) int {
+	if policy, ok := p[class]; ok {
+		return policy
+	}
+	return DefaultErrorPolicies()[class]
+}
+
+// errorHandler reports errors of reading and applies the policies to them
+type errorHandler struct {
+	policies ErrorPolicies
+	onError  func(err *ReadError)
+}
+
+// handle reports the error and returns the policy applied to it
+// retries is number of transient errors in a row, the file is skipped once it exceeds MAX_RETRIES
+// skip moves position over the entry which cannot be read, the file is skipped if it is nil or it fails
+// errors not related to any object, e.g. of the header, cannot be skipped as well
+func (h errorHandler) handle(path string, err error, retries int, skip func() error) (int, *ReadError) {
+	readError := newReadError(path, 0, err)
+	if h.onError != nil {
+		h.onError(readError)
+	}
+
+	policy := h.policies.policy(readError.Class)
+	switch {
+	case policy == POLICY_RETRY && retries >= MAX_RETRIES:
+		policy = POLICY_SKIP_FILE
+	// position cannot be moved, e.g. the entry array is corrupted as well
+	case policy == POLICY_SKIP_ENTRY && (skip == nil || readError.Offset == 0 || skip() != nil):
+		policy = POLICY_SKIP_FILE
+	}
+
+	return policy, readError
+}
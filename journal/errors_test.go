@@ -0,0 +1,258 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "truncated file",
+			err:      fmt.Errorf("range (offset: 1, size: 2) is %w (0)", errOutOfFile),
+			expected: ERROR_TRANSIENT,
+		},
+		{
+			name:     "incomplete header",
+			err:      fmt.Errorf("not enough data (0) to read the header: %w", io.ErrUnexpectedEOF),
+			expected: ERROR_TRANSIENT,
+		},
+		{
+			name:     "read failure",
+			err:      &fs.PathError{Op: "read", Path: "system.journal", Err: errors.New("input/output error")},
+			expected: ERROR_TRANSIENT,
+		},
+		{
+			name:     "unknown flags",
+			err:      fmt.Errorf("file has unknown incompatible flags (32): %w", errors.ErrUnsupported),
+			expected: ERROR_UNSUPPORTED,
+		},
+		{
+			name:     "inconsistent object",
+			err:      errors.New("object at 16 is not a data object (3)"),
+			expected: ERROR_CORRUPT,
+		},
+		{
+			name:     "corruption found by verification",
+			err:      corrupted(16, "invalid hash of data object"),
+			expected: ERROR_CORRUPT,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, classifyError(tt.err))
+		})
+	}
+}
+
+func TestErrorPolicies(t *testing.T) {
+	policies := ErrorPolicies{ERROR_CORRUPT: POLICY_ABORT}
+	assert.Equal(t, POLICY_ABORT, policies.policy(ERROR_CORRUPT))
+	assert.Equal(t, POLICY_RETRY, policies.policy(ERROR_TRANSIENT))
+	assert.Equal(t, POLICY_SKIP_FILE, ErrorPolicies(nil).policy(ERROR_UNSUPPORTED))
+
+	handler := errorHandler{}
+	err := fmt.Errorf("range (offset: 1, size: 2) is %w (0)", errOutOfFile)
+
+	policy, readError := handler.handle("system.journal", err, MAX_RETRIES-1, nil)
+	assert.Equal(t, POLICY_RETRY, policy)
+	assert.Equal(t, "system.journal", readError.Path)
+	assert.ErrorIs(t, readError, errOutOfFile)
+
+	// file is skipped once the error doesn't go away
+	policy, _ = handler.handle("system.journal", err, MAX_RETRIES, nil)
+	assert.Equal(t, POLICY_SKIP_FILE, policy)
+
+	// entry cannot be skipped without position
+	policy, _ = handler.handle("system.journal", newReadError("system.journal", 16, errors.New("corrupted")), 0, nil)
+	assert.Equal(t, POLICY_SKIP_FILE, policy)
+}
+
+// dataOffset returns offset of the Data object with the given payload referenced by the entry with the given seqnum
+func dataOffset(t *testing.T, path string, seqnum uint64, payload string) uint64 {
+	reader, err := NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	for {
+		entry, err := reader.getNextEntry()
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+		if entry.seqnum != seqnum {
+			continue
+		}
+
+		for _, item := range entry.items() {
+			data, err := reader.getData(item.object_offset)
+			require.NoError(t, err)
+			dataPayload, err := data.getPayload(DEFAULT_MAX_DATA_SIZE)
+			require.NoError(t, err)
+			if string(dataPayload) == payload {
+				return item.object_offset
+			}
+		}
+		require.Failf(t, "data not found", "entry %d doesn't reference %s", seqnum, payload)
+	}
+}
+
+func TestMergedReaderErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flags    byte
+		policies ErrorPolicies
+		expected []string
+		class    int
+		aborted  bool
+	}{
+		{
+			name:     "corrupted entry is skipped",
+			flags:    OBJECT_COMPRESSED_XZ,
+			expected: []string{"first", "third"},
+			class:    ERROR_CORRUPT,
+		},
+		{
+			name:     "file with unsupported feature is skipped",
+			flags:    0x80,
+			expected: []string{"first"},
+			class:    ERROR_UNSUPPORTED,
+		},
+		{
+			name:     "corrupted file is skipped",
+			flags:    OBJECT_COMPRESSED_XZ,
+			policies: ErrorPolicies{ERROR_CORRUPT: POLICY_SKIP_FILE},
+			expected: []string{"first"},
+			class:    ERROR_CORRUPT,
+		},
+		{
+			name:     "reading is aborted",
+			flags:    OBJECT_COMPRESSED_XZ,
+			policies: ErrorPolicies{ERROR_CORRUPT: POLICY_ABORT},
+			expected: []string{"first"},
+			class:    ERROR_CORRUPT,
+			aborted:  true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "system.journal")
+			writer, err := NewWriter(path, WriterConfig{})
+			require.NoError(t, err)
+			writeMessages(t, writer, []uint64{1, 2, 3}, []string{"first", "second", "third"})
+			require.NoError(t, writer.Close())
+
+			// flags are stored right after the object type
+			offset := dataOffset(t, path, 2, "MESSAGE=second")
+			file, err := os.OpenFile(path, os.O_RDWR, 0)
+			require.NoError(t, err)
+			_, err = file.WriteAt([]byte{tt.flags}, int64(offset)+1)
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			reader, err := NewReader(path)
+			require.NoError(t, err)
+			merged := NewMergedReader(reader)
+			defer merged.Close()
+
+			errs := []*ReadError{}
+			merged.SetErrorHandler(tt.policies, func(err *ReadError) {
+				errs = append(errs, err)
+			})
+
+			messages := []string{}
+			for {
+				log, err := merged.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if tt.aborted && err != nil {
+					var readError *ReadError
+					require.ErrorAs(t, err, &readError)
+					assert.Equal(t, offset, readError.Offset)
+					break
+				}
+				require.NoError(t, err)
+
+				message, _ := log.Get("MESSAGE")
+				messages = append(messages, message)
+			}
+
+			assert.Equal(t, tt.expected, messages)
+			require.Len(t, errs, 1)
+			assert.Equal(t, path, errs[0].Path)
+			assert.Equal(t, offset, errs[0].Offset)
+			assert.Equal(t, tt.class, errs[0].Class)
+		})
+	}
+}
+
+func TestDirectoryReaderInvalidPattern(t *testing.T) {
+	dr := NewDirectoryReader()
+
+	err := dr.Monitor(context.Background(), []string{filepath.Join(t.TempDir(), "[")})
+	assert.ErrorIs(t, err, filepath.ErrBadPattern)
+
+	// Read stops along with Monitor
+	err = dr.Read(FilterChain{}, logWriterFunc(func(log Log) error { return nil }))
+	assert.ErrorIs(t, err, filepath.ErrBadPattern)
+}
+
+func TestDirectoryReaderFailedFile(t *testing.T) {
+	directory := t.TempDir()
+	include := []string{filepath.Join(directory, "*.journal")}
+	path := filepath.Join(directory, "system.journal")
+
+	writer, err := NewWriter(path, WriterConfig{})
+	require.NoError(t, err)
+	writeMessages(t, writer, []uint64{1}, []string{"first"})
+	require.NoError(t, writer.Close())
+
+	// incompatible flags are stored right after signature and compatible flags
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte{0x80}, 15)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	events := []int{}
+	errs := []*ReadError{}
+	dr := NewDirectoryReader()
+	dr.OnFileEvent = func(event FileEvent) {
+		events = append(events, event.Type)
+	}
+	dr.OnError = func(err *ReadError) {
+		errs = append(errs, err)
+	}
+
+	changed, err := dr.scan(include, true)
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	// failed file is not opened again
+	changed, err = dr.scan(include, false)
+	require.NoError(t, err)
+	assert.False(t, changed)
+
+	assert.Equal(t, []int{FILE_FAILED}, events)
+	require.Len(t, errs, 1)
+	assert.Equal(t, ERROR_UNSUPPORTED, errs[0].Class)
+	assert.Empty(t, dr.merged.heads)
+
+	// reading can be aborted on opening as well
+	dr = NewDirectoryReader()
+	dr.ErrorPolicies = ErrorPolicies{ERROR_UNSUPPORTED: POLICY_ABORT}
+	_, err = dr.scan(include, true)
+	assert.ErrorIs(t, err, errors.ErrUnsupported)
+}
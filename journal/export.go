@@ -0,0 +1,163 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// This implementation base on https://systemd.io/JOURNAL_EXPORT_FORMATS/#journal-export-format
+
+// ExportWriter serializes Logs to Journal Export Format
+type ExportWriter struct {
+	writer *bufio.Writer
+}
+
+// NewExportWriter returns ExportWriter writing to w
+func NewExportWriter(w io.Writer) *ExportWriter {
+	return &ExportWriter{
+		writer: bufio.NewWriter(w),
+	}
+}
+
+// isExportText returns true if the value can be serialized as KEY=value line
+// values which are not valid UTF-8 or contain control characters other than tab are binary
+func isExportText(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+
+	for _, r := range string(value) {
+		if (r < ' ' && r != '\t') || (r >= 0x7f && r <= 0x9f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Write serializes the Log followed by empty line
+// output is flushed, so every entry is available for the reader immediately
+func (ew *ExportWriter) Write(log Log) error {
+	for _, field := range log.Fields() {
+		if isExportText(field.Value) {
+			ew.writer.WriteString(field.Name)
+			ew.writer.WriteByte('=')
+			ew.writer.Write(field.Value)
+			ew.writer.WriteByte('\n')
+			continue
+		}
+
+		// KEY, new line, le64 size, value and new line
+		ew.writer.WriteString(field.Name)
+		ew.writer.WriteByte('\n')
+		ew.writer.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(field.Value))))
+		ew.writer.Write(field.Value)
+		ew.writer.WriteByte('\n')
+	}
+	ew.writer.WriteByte('\n')
+
+	return ew.writer.Flush()
+}
+
+// ExportReader parses stream in Journal Export Format
+type ExportReader struct {
+	reader *bufio.Reader
+
+	// MaxDataSize is maximum size of the single binary field, bigger fields fail to read
+	MaxDataSize int
+}
+
+// NewExportReader returns ExportReader reading from r
+func NewExportReader(r io.Reader) *ExportReader {
+	return &ExportReader{
+		reader:      bufio.NewReader(r),
+		MaxDataSize: DEFAULT_MAX_DATA_SIZE,
+	}
+}
+
+// Read returns the next Log from the stream
+// it returns io.EOF if there are no more entries
+func (er *ExportReader) Read() (Log, error) {
+	log := Log{}
+
+	for {
+		line, err := er.reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return Log{}, fmt.Errorf("incomplete line %q: %w", line, io.ErrUnexpectedEOF)
+			}
+			// the last entry doesn't have to be followed by empty line
+			if len(log.Fields()) > 0 {
+				return log, nil
+			}
+			return Log{}, io.EOF
+		}
+		if err != nil {
+			return Log{}, err
+		}
+		line = line[:len(line)-1]
+
+		// empty line ends the entry, subsequent empty lines are skipped
+		if len(line) == 0 {
+			if len(log.Fields()) == 0 {
+				continue
+			}
+			return log, nil
+		}
+
+		if key, value, found := bytes.Cut(line, []byte("=")); found {
+			if len(key) == 0 {
+				return Log{}, fmt.Errorf("field without name: %q", line)
+			}
+			log.Add(string(key), value)
+			continue
+		}
+
+		value, err := er.readBinary()
+		if err != nil {
+			return Log{}, fmt.Errorf("cannot read binary field %s: %w", line, err)
+		}
+		log.Add(string(line), value)
+	}
+}
+
+// readBinary reads value of the binary field, which is le64 size, data and new line
+func (er *ExportReader) readBinary() ([]byte, error) {
+	buffer := [8]byte{}
+	if _, err := io.ReadFull(er.reader, buffer[:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	size := le64(buffer)
+	if size > uint64(er.MaxDataSize) {
+		return nil, fmt.Errorf("field is too big (%d)", size)
+	}
+
+	value := make([]byte, size)
+	if _, err := io.ReadFull(er.reader, value); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	newLine, err := er.reader.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if newLine != '\n' {
+		return nil, errors.New("field is not followed by new line")
+	}
+
+	return value, nil
+}
+
+// unexpectedEOF converts io.EOF to io.ErrUnexpectedEOF as the stream ended in the middle of the field
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
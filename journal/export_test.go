@@ -0,0 +1,224 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsExportText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{
+			name:     "empty",
+			value:    "",
+			expected: true,
+		},
+		{
+			name:     "text",
+			value:    "Started session-12.scope - Session 12 of User root.",
+			expected: true,
+		},
+		{
+			name:     "tab",
+			value:    "a\tb",
+			expected: true,
+		},
+		{
+			name:     "utf-8",
+			value:    "zażółć gęślą jaźń",
+			expected: true,
+		},
+		{
+			name:     "new line",
+			value:    "first\nsecond",
+			expected: false,
+		},
+		{
+			name:     "carriage return",
+			value:    "first\r",
+			expected: false,
+		},
+		{
+			name:     "c1 control character",
+			value:    "a\u0085b",
+			expected: false,
+		},
+		{
+			name:     "invalid utf-8",
+			value:    "\xff\xfe",
+			expected: false,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isExportText([]byte(tt.value)))
+		})
+	}
+}
+
+func TestExportWriter(t *testing.T) {
+	buffer := bytes.Buffer{}
+	writer := NewExportWriter(&buffer)
+
+	err := writer.Write(testLog(
+		ATTRIBUTE_CURSOR, "s=1;i=1",
+		ATTRIBUTE_REALTIME_TIMESTAMP, "1700000000000000",
+		ATTRIBUTE_MONOTONIC_TIMESTAMP, "1000",
+		"MESSAGE", "first\nsecond",
+		"_PID", "42",
+		"TAG", "a",
+		"TAG", "b",
+	))
+	require.NoError(t, err)
+	err = writer.Write(testLog(
+		"BINARY", "\x00\x01",
+	))
+	require.NoError(t, err)
+
+	expected := "__CURSOR=s=1;i=1\n" +
+		"__REALTIME_TIMESTAMP=1700000000000000\n" +
+		"__MONOTONIC_TIMESTAMP=1000\n" +
+		"MESSAGE\n\x0c\x00\x00\x00\x00\x00\x00\x00first\nsecond\n" +
+		"_PID=42\n" +
+		"TAG=a\n" +
+		"TAG=b\n" +
+		"\n" +
+		"BINARY\n\x02\x00\x00\x00\x00\x00\x00\x00\x00\x01\n" +
+		"\n"
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestExportReader(t *testing.T) {
+	input := "\n" +
+		"__CURSOR=s=1;i=1\n" +
+		"__REALTIME_TIMESTAMP=1700000000000000\n" +
+		"MESSAGE\n\x0c\x00\x00\x00\x00\x00\x00\x00first\nsecond\n" +
+		"EMPTY=\n" +
+		"VALUE=a=b\n" +
+		"TAG=a\n" +
+		"TAG=b\n" +
+		"\n" +
+		"\n" +
+		"_PID=42\n"
+
+	reader := NewExportReader(strings.NewReader(input))
+
+	log, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, testLog(
+		ATTRIBUTE_CURSOR, "s=1;i=1",
+		ATTRIBUTE_REALTIME_TIMESTAMP, "1700000000000000",
+		"MESSAGE", "first\nsecond",
+		"EMPTY", "",
+		"VALUE", "a=b",
+		"TAG", "a",
+		"TAG", "b",
+	), log)
+
+	// the last entry doesn't end with empty line
+	log, err = reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, testLog("_PID", "42"), log)
+
+	_, err = reader.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestExportReaderErrors(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		expectedError error
+	}{
+		{
+			name:          "incomplete line",
+			input:         "MESSAGE=first",
+			expectedError: io.ErrUnexpectedEOF,
+		},
+		{
+			name:          "incomplete size",
+			input:         "MESSAGE\n\x0c\x00\x00",
+			expectedError: io.ErrUnexpectedEOF,
+		},
+		{
+			name:          "incomplete data",
+			input:         "MESSAGE\n\x0c\x00\x00\x00\x00\x00\x00\x00first",
+			expectedError: io.ErrUnexpectedEOF,
+		},
+		{
+			name:          "missing new line",
+			input:         "MESSAGE\n\x0c\x00\x00\x00\x00\x00\x00\x00first\nsecond",
+			expectedError: io.ErrUnexpectedEOF,
+		},
+		{
+			name:  "invalid new line",
+			input: "MESSAGE\n\x0c\x00\x00\x00\x00\x00\x00\x00first\nsecondX\n",
+		},
+		{
+			name:  "too big",
+			input: "MESSAGE\n\xff\xff\xff\xff\xff\xff\xff\xff",
+		},
+		{
+			name:  "no field name",
+			input: "=value\n",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExportReader(strings.NewReader(tt.input)).Read()
+			require.Error(t, err)
+			assert.False(t, errors.Is(err, io.EOF))
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			}
+		})
+	}
+}
+
+func TestExportReaderMaxDataSize(t *testing.T) {
+	input := "MESSAGE\n\x05\x00\x00\x00\x00\x00\x00\x00first\n\n" +
+		"MESSAGE\n\x06\x00\x00\x00\x00\x00\x00\x00second\n"
+
+	reader := NewExportReader(strings.NewReader(input))
+	reader.MaxDataSize = 5
+
+	log, err := reader.Read()
+	require.NoError(t, err)
+	assert.Equal(t, testLog("MESSAGE", "first"), log)
+
+	_, err = reader.Read()
+	assert.ErrorContains(t, err, "field is too big (6)")
+}
+
+func TestExportRoundTrip(t *testing.T) {
+	logs := []Log{
+		testLog("MESSAGE", "text", "_PID", "1"),
+		testLog("MESSAGE", "multi\nline", "COREDUMP", "\x7fELF\x00\x01"),
+		testLog("MESSAGE", ""),
+		testLog("TAG", "a", "MESSAGE", "repeated", "TAG", "b", "TAG", "a"),
+	}
+
+	buffer := bytes.Buffer{}
+	writer := NewExportWriter(&buffer)
+	for _, log := range logs {
+		require.NoError(t, writer.Write(log))
+	}
+
+	reader := NewExportReader(&buffer)
+	for _, log := range logs {
+		read, err := reader.Read()
+		require.NoError(t, err)
+		assert.Equal(t, log, read)
+	}
+	_, err := reader.Read()
+	assert.ErrorIs(t, err, io.EOF)
+}
@@ -0,0 +1,358 @@
+package journal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SyntaxError describes invalid filter expression
+type SyntaxError struct {
+	// position of the invalid part of the expression, counted in characters from 1
+	Column int
+	Msg    string
+}
+
+// Error implements error interface
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("invalid filter expression at column %d: %s", e.Column, e.Msg)
+}
+
+// expressionOperators maps operators of the expression to the filter operators and Keep
+// longer operators go first, so they are not taken for their prefixes
+var expressionOperators = []struct {
+	symbol   string
+	operator int
+	keep     bool
+}{
+	{"!=", FILTER_EQUAL, false},
+	{"=~", FILTER_REGEX, true},
+	{"!~", FILTER_REGEX, false},
+	{"<=", FILTER_LESS_EQUAL, true},
+	{">=", FILTER_GREATER_EQUAL, true},
+	{"=", FILTER_EQUAL, true},
+	{"<", FILTER_LESS, true},
+	{">", FILTER_GREATER, true},
+}
+
+// ParseFilter parses the filter expression to the filter chain
+//
+// Matches FIELD=value are given the same way as for journalctl, adjacent matches of the same field
+// are joined with OR and matches of different fields with AND, + separates alternatives:
+//
+//	_SYSTEMD_UNIT=ssh.service _SYSTEMD_UNIT=cron.service PRIORITY=3 + _TRANSPORT=kernel
+//
+// Expressions can be grouped with parentheses and combined with AND, OR and NOT as well.
+// Operators != (not equal), =~ and !~ (regular expression) and <, <=, >, >= (number) are supported.
+// Values containing spaces or parentheses are quoted with double quotes as Go strings:
+//
+//	NOT (_COMM=~"^(sshd|cron)$" OR MESSAGE="Started session") AND _PID>1000
+//
+// Empty expression matches all entries. SyntaxError is returned if the expression is invalid.
+func ParseFilter(expression string) (FilterChain, error) {
+	p := expressionParser{expression: expression}
+	if p.end() {
+		return FilterChain{}, nil
+	}
+
+	chain, err := p.parseOr()
+	if err != nil {
+		return FilterChain{}, err
+	}
+	if !p.end() {
+		return FilterChain{}, p.unexpected()
+	}
+
+	return chain, nil
+}
+
+// expressionParser is recursive descent parser of the filter expression
+type expressionParser struct {
+	expression string
+	// offset of the next character to parse
+	offset int
+}
+
+// syntaxError returns SyntaxError at the given offset
+func (p *expressionParser) syntaxError(offset int, format string, args ...any) error {
+	return &SyntaxError{
+		Column: utf8.RuneCountInString(p.expression[:offset]) + 1,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// unexpected returns SyntaxError for the character at the current offset
+func (p *expressionParser) unexpected() error {
+	if p.end() {
+		return p.syntaxError(p.offset, "unexpected end of expression")
+	}
+	r, _ := utf8.DecodeRuneInString(p.expression[p.offset:])
+	return p.syntaxError(p.offset, "unexpected %q", r)
+}
+
+// end skips spaces and returns true if there is nothing more to parse
+func (p *expressionParser) end() bool {
+	for p.offset < len(p.expression) && isExpressionSpace(p.expression[p.offset]) {
+		p.offset++
+	}
+	return p.offset == len(p.expression)
+}
+
+// symbol consumes the symbol if it is next in the expression
+func (p *expressionParser) symbol(symbol string) bool {
+	if p.end() || !strings.HasPrefix(p.expression[p.offset:], symbol) {
+		return false
+	}
+	p.offset += len(symbol)
+	return true
+}
+
+// isKeyword returns true if the keyword is next in the expression
+// keyword has to be separated from the following field name, e.g. ANDROID=1 is a match
+func (p *expressionParser) isKeyword(keyword string) bool {
+	if p.end() || !strings.HasPrefix(p.expression[p.offset:], keyword) {
+		return false
+	}
+	next := p.offset + len(keyword)
+	return next == len(p.expression) || !isFieldNameCharacter(p.expression[next]) && !isOperatorCharacter(p.expression[next])
+}
+
+// keyword consumes the keyword if it is next in the expression
+func (p *expressionParser) keyword(keyword string) bool {
+	if !p.isKeyword(keyword) {
+		return false
+	}
+	p.offset += len(keyword)
+	return true
+}
+
+// parseOr parses alternatives separated by OR or +
+func (p *expressionParser) parseOr() (FilterChain, error) {
+	chains := []FilterChain{}
+	for {
+		chain, err := p.parseAnd()
+		if err != nil {
+			return FilterChain{}, err
+		}
+		chains = append(chains, chain)
+
+		if !p.keyword("OR") && !p.symbol("+") {
+			return joinChains(true, chains), nil
+		}
+	}
+}
+
+// parseAnd parses groups separated by AND
+func (p *expressionParser) parseAnd() (FilterChain, error) {
+	chains := []FilterChain{}
+	for {
+		chain, err := p.parseGroup()
+		if err != nil {
+			return FilterChain{}, err
+		}
+		chains = append(chains, chain)
+
+		if !p.keyword("AND") {
+			return joinChains(false, chains), nil
+		}
+	}
+}
+
+// parseGroup parses adjacent operands, which are joined with AND
+// positive matches of the same field are joined with OR, the same as journalctl does
+func (p *expressionParser) parseGroup() (FilterChain, error) {
+	chains := []FilterChain{}
+	// index of the chain with equality match of the field
+	fields := map[string]int{}
+
+	for {
+		chain, err := p.parseUnary()
+		if err != nil {
+			return FilterChain{}, err
+		}
+
+		if filter, ok := equalityMatch(chain); ok {
+			if i, ok := fields[filter.Name]; ok {
+				chains[i].Filters[0].Matches = append(chains[i].Filters[0].Matches, filter.Matches...)
+				chain = FilterChain{}
+			} else {
+				fields[filter.Name] = len(chains)
+			}
+		}
+		if len(chain.Filters) > 0 || len(chain.FilterChains) > 0 {
+			chains = append(chains, chain)
+		}
+
+		if p.end() || p.expression[p.offset] == ')' || p.expression[p.offset] == '+' || p.isKeyword("AND") || p.isKeyword("OR") {
+			return joinChains(false, chains), nil
+		}
+	}
+}
+
+// parseUnary parses negated operand, expression in parentheses or match
+func (p *expressionParser) parseUnary() (FilterChain, error) {
+	if p.keyword("NOT") {
+		chain, err := p.parseUnary()
+		if err != nil {
+			return FilterChain{}, err
+		}
+		return negateChain(chain), nil
+	}
+
+	if p.end() {
+		return FilterChain{}, p.unexpected()
+	}
+
+	start := p.offset
+	if p.symbol("(") {
+		chain, err := p.parseOr()
+		if err != nil {
+			return FilterChain{}, err
+		}
+		if !p.symbol(")") {
+			if p.end() {
+				return FilterChain{}, p.syntaxError(start, "unclosed parenthesis")
+			}
+			return FilterChain{}, p.unexpected()
+		}
+		return chain, nil
+	}
+
+	return p.parseMatch()
+}
+
+// parseMatch parses FIELD, operator and value
+func (p *expressionParser) parseMatch() (FilterChain, error) {
+	start := p.offset
+	for p.offset < len(p.expression) && isFieldNameCharacter(p.expression[p.offset]) {
+		p.offset++
+	}
+	name := p.expression[start:p.offset]
+	if name == "" {
+		return FilterChain{}, p.unexpected()
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		return FilterChain{}, p.syntaxError(start, "field name %q starts with digit", name)
+	}
+
+	filter := Filter{Name: name}
+	found := false
+	for _, operator := range expressionOperators {
+		if strings.HasPrefix(p.expression[p.offset:], operator.symbol) {
+			filter.Operator, filter.Keep = operator.operator, operator.keep
+			p.offset += len(operator.symbol)
+			found = true
+			break
+		}
+	}
+	if !found {
+		if p.offset < len(p.expression) && p.expression[p.offset] >= 'a' && p.expression[p.offset] <= 'z' {
+			return FilterChain{}, p.syntaxError(p.offset, "field name can contain only uppercase letters, digits and underscores")
+		}
+		if p.offset < len(p.expression) && !isExpressionSpace(p.expression[p.offset]) {
+			return FilterChain{}, p.unexpected()
+		}
+		return FilterChain{}, p.syntaxError(p.offset, "missing operator after field %s", name)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return FilterChain{}, err
+	}
+	filter.Matches = []string{value}
+
+	if err := filter.Validate(); err != nil {
+		return FilterChain{}, p.syntaxError(start, "%v", err)
+	}
+
+	return FilterChain{Filters: []Filter{filter}}, nil
+}
+
+// parseValue parses quoted value or the value ending with space or parenthesis
+func (p *expressionParser) parseValue() (string, error) {
+	start := p.offset
+
+	if p.offset < len(p.expression) && p.expression[p.offset] == '"' {
+		p.offset++
+		for p.offset < len(p.expression) && p.expression[p.offset] != '"' {
+			if p.expression[p.offset] == '\\' {
+				p.offset++
+			}
+			p.offset++
+		}
+		if p.offset >= len(p.expression) {
+			return "", p.syntaxError(start, "unterminated quoted value")
+		}
+		p.offset++
+
+		value, err := strconv.Unquote(p.expression[start:p.offset])
+		if err != nil {
+			return "", p.syntaxError(start, "invalid quoted value: %v", err)
+		}
+		return value, nil
+	}
+
+	for p.offset < len(p.expression) && !isExpressionSpace(p.expression[p.offset]) && p.expression[p.offset] != ')' {
+		p.offset++
+	}
+	return p.expression[start:p.offset], nil
+}
+
+// isExpressionSpace returns true if the character separates tokens of the expression
+func isExpressionSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// isFieldNameCharacter returns true if the character can be used in the field name
+func isFieldNameCharacter(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+// isOperatorCharacter returns true if the character starts any operator
+func isOperatorCharacter(c byte) bool {
+	return c == '=' || c == '!' || c == '<' || c == '>'
+}
+
+// equalityMatch returns the filter if the chain is single positive equality match
+func equalityMatch(chain FilterChain) (Filter, bool) {
+	if len(chain.Filters) != 1 || len(chain.FilterChains) != 0 {
+		return Filter{}, false
+	}
+	filter := chain.Filters[0]
+	return filter, filter.Keep && filter.Operator == FILTER_EQUAL
+}
+
+// joinChains joins the chains with OR or AND
+// single chains and chains using the same operator are flattened, so the index can be used for their filters
+func joinChains(operatorOr bool, chains []FilterChain) FilterChain {
+	if len(chains) == 1 {
+		return chains[0]
+	}
+
+	joined := FilterChain{OperatorOr: operatorOr}
+	for _, chain := range chains {
+		single := len(chain.Filters)+len(chain.FilterChains) == 1
+		if chain.OperatorOr != operatorOr && !single {
+			joined.FilterChains = append(joined.FilterChains, chain)
+			continue
+		}
+		joined.Filters = append(joined.Filters, chain.Filters...)
+		joined.FilterChains = append(joined.FilterChains, chain.FilterChains...)
+	}
+	return joined
+}
+
+// negateChain returns chain matching the entries not matched by the chain
+// filters are negated by Keep and operators are swapped according to De Morgan's laws
+func negateChain(chain FilterChain) FilterChain {
+	negated := FilterChain{OperatorOr: !chain.OperatorOr}
+	for _, filter := range chain.Filters {
+		filter.Keep = !filter.Keep
+		negated.Filters = append(negated.Filters, filter)
+	}
+	for _, nested := range chain.FilterChains {
+		negated.FilterChains = append(negated.FilterChains, negateChain(nested))
+	}
+	return negated
+}
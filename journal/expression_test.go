@@ -0,0 +1,157 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	testCases := []struct {
+		name       string
+		expression string
+		expected   FilterChain
+	}{
+		{
+			name:       "empty",
+			expression: " ",
+			expected:   FilterChain{},
+		},
+		{
+			name:       "single match",
+			expression: "_SYSTEMD_UNIT=ssh.service",
+			expected: FilterChain{Filters: []Filter{
+				{Name: "_SYSTEMD_UNIT", Matches: []string{"ssh.service"}, Keep: true},
+			}},
+		},
+		{
+			name:       "positional matches",
+			expression: "_SYSTEMD_UNIT=ssh.service PRIORITY=3 _SYSTEMD_UNIT=cron.service",
+			expected: FilterChain{Filters: []Filter{
+				{Name: "_SYSTEMD_UNIT", Matches: []string{"ssh.service", "cron.service"}, Keep: true},
+				{Name: "PRIORITY", Matches: []string{"3"}, Keep: true},
+			}},
+		},
+		{
+			name:       "disjunction",
+			expression: "_SYSTEMD_UNIT=ssh.service PRIORITY=3 + _TRANSPORT=kernel",
+			expected: FilterChain{OperatorOr: true, FilterChains: []FilterChain{{Filters: []Filter{
+				{Name: "_SYSTEMD_UNIT", Matches: []string{"ssh.service"}, Keep: true},
+				{Name: "PRIORITY", Matches: []string{"3"}, Keep: true},
+			}}}, Filters: []Filter{
+				{Name: "_TRANSPORT", Matches: []string{"kernel"}, Keep: true},
+			}},
+		},
+		{
+			name:       "explicit AND doesn't join the same field",
+			expression: "_PID=1 AND _PID=2",
+			expected: FilterChain{Filters: []Filter{
+				{Name: "_PID", Matches: []string{"1"}, Keep: true},
+				{Name: "_PID", Matches: []string{"2"}, Keep: true},
+			}},
+		},
+		{
+			name:       "operators",
+			expression: `_COMM!=cron MESSAGE=~"^Started " _EXE!~^/usr _PID>1000 _UID<=100 LOAD<0.5 _GID>=0 ERRNO<1`,
+			expected: FilterChain{Filters: []Filter{
+				{Name: "_COMM", Matches: []string{"cron"}},
+				{Name: "MESSAGE", Matches: []string{"^Started "}, Keep: true, Operator: FILTER_REGEX},
+				{Name: "_EXE", Matches: []string{"^/usr"}, Operator: FILTER_REGEX},
+				{Name: "_PID", Matches: []string{"1000"}, Keep: true, Operator: FILTER_GREATER},
+				{Name: "_UID", Matches: []string{"100"}, Keep: true, Operator: FILTER_LESS_EQUAL},
+				{Name: "LOAD", Matches: []string{"0.5"}, Keep: true, Operator: FILTER_LESS},
+				{Name: "_GID", Matches: []string{"0"}, Keep: true, Operator: FILTER_GREATER_EQUAL},
+				{Name: "ERRNO", Matches: []string{"1"}, Keep: true, Operator: FILTER_LESS},
+			}},
+		},
+		{
+			name:       "parentheses",
+			expression: "(_PID=1 OR _PID=2) AND (_COMM=a OR _COMM=b)",
+			expected: FilterChain{FilterChains: []FilterChain{
+				{OperatorOr: true, Filters: []Filter{
+					{Name: "_PID", Matches: []string{"1"}, Keep: true},
+					{Name: "_PID", Matches: []string{"2"}, Keep: true},
+				}},
+				{OperatorOr: true, Filters: []Filter{
+					{Name: "_COMM", Matches: []string{"a"}, Keep: true},
+					{Name: "_COMM", Matches: []string{"b"}, Keep: true},
+				}},
+			}},
+		},
+		{
+			name:       "negation",
+			expression: "NOT(_PID=1 AND NOT _COMM=a)",
+			expected: FilterChain{OperatorOr: true, Filters: []Filter{
+				{Name: "_PID", Matches: []string{"1"}},
+				{Name: "_COMM", Matches: []string{"a"}, Keep: true},
+			}},
+		},
+		{
+			name:       "keywords as parts of field names",
+			expression: "ANDROID=1 NOTE=2 ORIGIN=3",
+			expected: FilterChain{Filters: []Filter{
+				{Name: "ANDROID", Matches: []string{"1"}, Keep: true},
+				{Name: "NOTE", Matches: []string{"2"}, Keep: true},
+				{Name: "ORIGIN", Matches: []string{"3"}, Keep: true},
+			}},
+		},
+		{
+			name:       "quoted and empty values",
+			expression: `MESSAGE="a (quoted) \"value\"" CODE_FILE=`,
+			expected: FilterChain{Filters: []Filter{
+				{Name: "MESSAGE", Matches: []string{`a (quoted) "value"`}, Keep: true},
+				{Name: "CODE_FILE", Matches: []string{""}, Keep: true},
+			}},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := ParseFilter(tt.expression)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, chain)
+		})
+	}
+}
+
+func TestParseFilterSyntaxError(t *testing.T) {
+	testCases := []struct {
+		expression string
+		column     int
+		message    string
+	}{
+		{expression: "_PID", column: 5, message: "missing operator after field _PID"},
+		{expression: "_PID=1 AND", column: 11, message: "unexpected end of expression"},
+		{expression: "(_PID=1 OR _PID=2", column: 1, message: "unclosed parenthesis"},
+		{expression: "_PID=1)", column: 7, message: `unexpected ')'`},
+		{expression: "()", column: 2, message: `unexpected ')'`},
+		{expression: "_pid=1", column: 2, message: "field name can contain only uppercase letters, digits and underscores"},
+		{expression: "1PID=1", column: 1, message: `field name "1PID" starts with digit`},
+		{expression: `MESSAGE="żółw`, column: 9, message: "unterminated quoted value"},
+		{expression: `_PID=1 MESSAGE=~"("`, column: 8},
+		{expression: "MESSAGE=żółw _PID>one", column: 14},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := ParseFilter(tt.expression)
+			var syntaxError *SyntaxError
+			require.ErrorAs(t, err, &syntaxError)
+			assert.Equal(t, tt.column, syntaxError.Column)
+			if tt.message != "" {
+				assert.Equal(t, tt.message, syntaxError.Msg)
+			}
+		})
+	}
+}
+
+func TestParseFilterMatching(t *testing.T) {
+	chain, err := ParseFilter(`NOT (_COMM=~"^(sshd|cron)$" OR MESSAGE="Started session") AND _PID>1000 + PRIORITY<=2`)
+	require.NoError(t, err)
+	require.NoError(t, chain.Validate())
+
+	assert.True(t, chain.filterIn(testLog("_COMM", "bash", "MESSAGE", "exit", "_PID", "1234")))
+	assert.False(t, chain.filterIn(testLog("_COMM", "sshd", "MESSAGE", "exit", "_PID", "1234")))
+	assert.False(t, chain.filterIn(testLog("_COMM", "bash", "MESSAGE", "Started session", "_PID", "1234")))
+	assert.False(t, chain.filterIn(testLog("_COMM", "bash", "MESSAGE", "exit", "_PID", "100")))
+	assert.True(t, chain.filterIn(testLog("_COMM", "sshd", "PRIORITY", "2")))
+}
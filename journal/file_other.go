@@ -0,0 +1,27 @@
+//go:build !unix
+
+package journal
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSupported is false, so files are read using ReadAt
+const mmapSupported = false
+
+// mapFile returns error as memory mapping is used on unix systems only
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+// unmapFile does nothing as memory mapping is used on unix systems only
+func unmapFile(data []byte) error {
+	return nil
+}
+
+// isRemoved returns false as number of links is not known
+// removed files are closed once they are archived and read completely
+func isRemoved(info os.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package journal
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapSupported is true as files are mapped to memory on unix systems
+const mmapSupported = true
+
+// mapFile maps the first size bytes of the file read-only
+// size cannot exceed maxMapSize, as length of the mapping is int
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+// unmapFile removes the mapping created by mapFile
+func unmapFile(data []byte) error {
+	return syscall.Munmap(data)
+}
+
+// isRemoved returns true if there is no link to the file left
+func isRemoved(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && stat.Nlink == 0
+}
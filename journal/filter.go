@@ -0,0 +1,407 @@
+package journal
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Definitions of the filter operators
+const (
+	// value is equal to one of the matches
+	FILTER_EQUAL = iota
+	// value starts with one of the matches
+	FILTER_PREFIX
+	// value ends with one of the matches
+	FILTER_SUFFIX
+	// value matches one of the shell patterns, see path.Match
+	FILTER_GLOB
+	// value matches one of the regular expressions, see regexp
+	FILTER_REGEX
+	// field is present in the entry, matches are ignored
+	FILTER_EXISTS
+	// field is not present in the entry, matches are ignored
+	FILTER_ABSENT
+	// value is a number lower than one of the matches
+	FILTER_LESS
+	// value is a number lower than or equal to one of the matches
+	FILTER_LESS_EQUAL
+	// value is a number greater than one of the matches
+	FILTER_GREATER
+	// value is a number greater than or equal to one of the matches
+	FILTER_GREATER_EQUAL
+)
+
+// Filter matches entries by values of the field
+// entry matches if any value of the field matches any of the matches using the operator
+// Keep set to false excludes the matching entries instead
+type Filter struct {
+	Name     string
+	Matches  []string
+	Keep     bool
+	Operator int
+}
+
+// regexps keeps compiled regular expressions of the filters
+var regexps sync.Map
+
+// compileRegexp returns compiled regular expression, which is compiled once
+func compileRegexp(expression string) (*regexp.Regexp, error) {
+	if compiled, ok := regexps.Load(expression); ok {
+		return compiled.(*regexp.Regexp), nil
+	}
+
+	compiled, err := regexp.Compile(expression)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, _ := regexps.LoadOrStore(expression, compiled)
+	return stored.(*regexp.Regexp), nil
+}
+
+// parseNumber parses integer or floating point number
+// integers are compared exactly, so big values like timestamps don't lose precision
+func parseNumber(value string) (int64, float64, bool, error) {
+	integer, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return integer, 0, true, nil
+	}
+
+	float, err := strconv.ParseFloat(value, 64)
+	return 0, float, false, err
+}
+
+// compareNumbers returns -1, 0 or 1 if value is lower, equal or greater than match
+// it returns false if any of them is not a number
+func compareNumbers(value string, match string) (int, bool) {
+	valueInteger, valueFloat, valueIsInteger, err := parseNumber(value)
+	if err != nil {
+		return 0, false
+	}
+	matchInteger, matchFloat, matchIsInteger, err := parseNumber(match)
+	if err != nil {
+		return 0, false
+	}
+
+	if valueIsInteger && matchIsInteger {
+		return cmp.Compare(valueInteger, matchInteger), true
+	}
+
+	if valueIsInteger {
+		valueFloat = float64(valueInteger)
+	}
+	if matchIsInteger {
+		matchFloat = float64(matchInteger)
+	}
+	return cmp.Compare(valueFloat, matchFloat), true
+}
+
+// Validate returns error if the filter cannot match anything because of invalid operator or matches
+func (f Filter) Validate() error {
+	if f.Name == "" {
+		return errors.New("filter has no field name")
+	}
+
+	for _, match := range f.Matches {
+		var err error
+		switch f.Operator {
+		case FILTER_EQUAL, FILTER_PREFIX, FILTER_SUFFIX, FILTER_EXISTS, FILTER_ABSENT:
+		case FILTER_GLOB:
+			_, err = path.Match(match, "")
+		case FILTER_REGEX:
+			_, err = compileRegexp(match)
+		case FILTER_LESS, FILTER_LESS_EQUAL, FILTER_GREATER, FILTER_GREATER_EQUAL:
+			_, _, _, err = parseNumber(match)
+		default:
+			return fmt.Errorf("filter for %s has unknown operator (%d)", f.Name, f.Operator)
+		}
+		if err != nil {
+			return fmt.Errorf("filter for %s has invalid match %q: %w", f.Name, match, err)
+		}
+	}
+
+	if len(f.Matches) == 0 && f.Operator != FILTER_EXISTS && f.Operator != FILTER_ABSENT {
+		return fmt.Errorf("filter for %s has no matches", f.Name)
+	}
+
+	return nil
+}
+
+// matchValue returns true if the value matches the match using the operator
+// invalid matches don't match anything
+func (f *Filter) matchValue(value []byte, match string) bool {
+	switch f.Operator {
+	case FILTER_EQUAL:
+		return string(value) == match
+	case FILTER_PREFIX:
+		return bytes.HasPrefix(value, []byte(match))
+	case FILTER_SUFFIX:
+		return bytes.HasSuffix(value, []byte(match))
+	case FILTER_GLOB:
+		matched, err := path.Match(match, string(value))
+		return err == nil && matched
+	case FILTER_REGEX:
+		compiled, err := compileRegexp(match)
+		return err == nil && compiled.Match(value)
+	case FILTER_LESS, FILTER_LESS_EQUAL, FILTER_GREATER, FILTER_GREATER_EQUAL:
+		c, ok := compareNumbers(string(value), match)
+		if !ok {
+			return false
+		}
+		switch f.Operator {
+		case FILTER_LESS:
+			return c < 0
+		case FILTER_LESS_EQUAL:
+			return c <= 0
+		case FILTER_GREATER:
+			return c > 0
+		default:
+			return c >= 0
+		}
+	}
+	return false
+}
+
+// matchValues returns true if any of the values matches any of the matches
+// field is checked for presence only by FILTER_EXISTS and FILTER_ABSENT
+func (f *Filter) matchValues(values [][]byte) bool {
+	switch f.Operator {
+	case FILTER_EXISTS:
+		return len(values) > 0
+	case FILTER_ABSENT:
+		return len(values) == 0
+	}
+
+	for _, value := range values {
+		for _, match := range f.Matches {
+			if f.matchValue(value, match) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// filterIn returns true if the entry matches the filter and Keep is true
+// or it doesn't match the filter and Keep is false
+func (f *Filter) filterIn(log Log) bool {
+	return f.matchValues(log.Values(f.Name)) == f.Keep
+}
+
+// FilterChain combines filters and nested chains using AND or OR if OperatorOr is true
+type FilterChain struct {
+	OperatorOr   bool
+	FilterChains []FilterChain
+	Filters      []Filter
+}
+
+// Validate returns error if any filter of the chain or nested chains is invalid
+func (fc FilterChain) Validate() error {
+	for _, chain := range fc.FilterChains {
+		if err := chain.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, filter := range fc.Filters {
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fc *FilterChain) filterIn(log Log) bool {
+	switch fc.OperatorOr {
+	case true:
+		for _, chain := range fc.FilterChains {
+			if chain.filterIn(log) {
+				return true
+			}
+		}
+		for _, filter := range fc.Filters {
+			if filter.filterIn(log) {
+				return true
+			}
+		}
+		return false
+	case false:
+		for _, chain := range fc.FilterChains {
+			if !chain.filterIn(log) {
+				return false
+			}
+		}
+		for _, filter := range fc.Filters {
+			if !filter.filterIn(log) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// entries returns sorted offsets of entries matching the filter using data hash table
+// all is set to true if the index cannot narrow the entries, e.g. for exclusions
+func (f *Filter) entries(r *Reader) (offsets []uint64, all bool, err error) {
+	switch {
+	// entries without the field or with other values are not indexed
+	case !f.Keep || f.Operator == FILTER_ABSENT:
+		return nil, true, nil
+	// attributes like __REALTIME_TIMESTAMP are not stored as Data objects
+	case strings.HasPrefix(f.Name, "__"):
+		return nil, true, nil
+	// exact values are found using data hash table
+	case f.Operator == FILTER_EQUAL:
+		offsets, err = r.matchingDataEntries(f.Name, f.Matches)
+	// other values are found using chain of all Data objects of the field
+	default:
+		offsets, err = r.matchingFieldEntries(f.Name, func(value []byte) bool {
+			return f.matchValues([][]byte{value})
+		})
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	slices.Sort(offsets)
+	return slices.Compact(offsets), false, nil
+}
+
+// matchingDataEntries returns offsets of entries containing any of the values of the field
+func (r *Reader) matchingDataEntries(name string, values []string) ([]uint64, error) {
+	offsets := []uint64{}
+
+	for _, value := range values {
+		data, err := r.findData([]byte(name + "=" + value))
+		if err != nil {
+			return nil, err
+		}
+
+		// there is no entry with such value
+		if data == nil {
+			continue
+		}
+
+		dataEntries, err := r.getDataEntries(data)
+		if err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, dataEntries...)
+	}
+
+	return offsets, nil
+}
+
+// matchingFieldEntries returns offsets of entries containing the field with value accepted by match
+// it follows the chain of Data objects of the field, so every value is checked once
+func (r *Reader) matchingFieldEntries(name string, match func(value []byte) bool) ([]uint64, error) {
+	offsets := []uint64{}
+
+	err := r.walkFieldData(name, func(data *Data, value string) error {
+		if !match([]byte(value)) {
+			return nil
+		}
+
+		dataEntries, err := r.getDataEntries(data)
+		if err != nil {
+			return err
+		}
+		offsets = append(offsets, dataEntries...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return offsets, nil
+}
+
+// entries returns sorted offsets of entries matching the filter chain using data hash table
+// all is set to true if the chain doesn't narrow the entries at all
+func (fc *FilterChain) entries(r *Reader) (offsets []uint64, all bool, err error) {
+	sets := [][]uint64{}
+
+	for _, chain := range fc.FilterChains {
+		chainOffsets, chainAll, err := chain.entries(r)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if chainAll {
+			// one of alternatives accepts everything
+			if fc.OperatorOr {
+				return nil, true, nil
+			}
+			// accepting everything doesn't narrow conjunction
+			continue
+		}
+		sets = append(sets, chainOffsets)
+	}
+
+	for _, filter := range fc.Filters {
+		filterOffsets, filterAll, err := filter.entries(r)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if filterAll {
+			if fc.OperatorOr {
+				return nil, true, nil
+			}
+			continue
+		}
+		sets = append(sets, filterOffsets)
+	}
+
+	switch fc.OperatorOr {
+	case true:
+		offsets = []uint64{}
+		for _, set := range sets {
+			offsets = append(offsets, set...)
+		}
+		slices.Sort(offsets)
+		return slices.Compact(offsets), false, nil
+	default:
+		// empty conjunction accepts everything
+		if len(sets) == 0 {
+			return nil, true, nil
+		}
+
+		offsets = sets[0]
+		for _, set := range sets[1:] {
+			offsets = intersectOffsets(offsets, set)
+		}
+		return offsets, false, nil
+	}
+}
+
+// intersectOffsets returns offsets which exist in both sorted slices
+func intersectOffsets(a []uint64, b []uint64) []uint64 {
+	result := []uint64{}
+
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			result = append(result, a[i])
+			i++
+			j++
+		}
+	}
+
+	return result
+}
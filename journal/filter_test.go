@@ -0,0 +1,459 @@
+package journal
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterIn(t *testing.T) {
+	log := testLog(
+		"MESSAGE", "Started ssh.service",
+		"_SYSTEMD_UNIT", "ssh.service",
+		"_PID", "1234",
+		"TAG", "a",
+		"TAG", "b",
+		"LOAD", "0.75",
+	)
+
+	testCases := []struct {
+		name     string
+		filter   Filter
+		expected bool
+	}{
+		{
+			name:     "equal",
+			filter:   Filter{Name: "_SYSTEMD_UNIT", Matches: []string{"cron.service", "ssh.service"}, Keep: true},
+			expected: true,
+		},
+		{
+			name:     "equal to repeated field",
+			filter:   Filter{Name: "TAG", Matches: []string{"b"}, Keep: true},
+			expected: true,
+		},
+		{
+			name:     "missing field",
+			filter:   Filter{Name: "_COMM", Matches: []string{"sshd"}, Keep: true},
+			expected: false,
+		},
+		{
+			name:     "excluded value",
+			filter:   Filter{Name: "_SYSTEMD_UNIT", Matches: []string{"ssh.service"}},
+			expected: false,
+		},
+		{
+			name:     "not excluded value",
+			filter:   Filter{Name: "_SYSTEMD_UNIT", Matches: []string{"cron.service"}},
+			expected: true,
+		},
+		{
+			name:     "exclusion of missing field",
+			filter:   Filter{Name: "_COMM", Matches: []string{"sshd"}},
+			expected: true,
+		},
+		{
+			name:     "prefix",
+			filter:   Filter{Name: "MESSAGE", Matches: []string{"Started"}, Keep: true, Operator: FILTER_PREFIX},
+			expected: true,
+		},
+		{
+			name:     "suffix",
+			filter:   Filter{Name: "_SYSTEMD_UNIT", Matches: []string{".socket"}, Keep: true, Operator: FILTER_SUFFIX},
+			expected: false,
+		},
+		{
+			name:     "glob",
+			filter:   Filter{Name: "_SYSTEMD_UNIT", Matches: []string{"ss?.*"}, Keep: true, Operator: FILTER_GLOB},
+			expected: true,
+		},
+		{
+			name:     "regular expression",
+			filter:   Filter{Name: "MESSAGE", Matches: []string{`^Started \w+\.service$`}, Keep: true, Operator: FILTER_REGEX},
+			expected: true,
+		},
+		{
+			name:     "excluded regular expression",
+			filter:   Filter{Name: "MESSAGE", Matches: []string{`ssh`}, Operator: FILTER_REGEX},
+			expected: false,
+		},
+		{
+			name:     "exists",
+			filter:   Filter{Name: "_PID", Keep: true, Operator: FILTER_EXISTS},
+			expected: true,
+		},
+		{
+			name:     "absent",
+			filter:   Filter{Name: "_PID", Keep: true, Operator: FILTER_ABSENT},
+			expected: false,
+		},
+		{
+			name:     "greater",
+			filter:   Filter{Name: "_PID", Matches: []string{"1000"}, Keep: true, Operator: FILTER_GREATER},
+			expected: true,
+		},
+		{
+			name:     "less or equal",
+			filter:   Filter{Name: "_PID", Matches: []string{"1234"}, Keep: true, Operator: FILTER_LESS_EQUAL},
+			expected: true,
+		},
+		{
+			name:     "less",
+			filter:   Filter{Name: "_PID", Matches: []string{"1234"}, Keep: true, Operator: FILTER_LESS},
+			expected: false,
+		},
+		{
+			name:     "greater or equal to fraction",
+			filter:   Filter{Name: "LOAD", Matches: []string{"0.5"}, Keep: true, Operator: FILTER_GREATER_EQUAL},
+			expected: true,
+		},
+		{
+			name:     "number compared with text",
+			filter:   Filter{Name: "MESSAGE", Matches: []string{"0"}, Keep: true, Operator: FILTER_GREATER},
+			expected: false,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.filterIn(log))
+		})
+	}
+}
+
+func TestFilterChainFilterIn(t *testing.T) {
+	log := testLog("_SYSTEMD_UNIT", "ssh.service", "PRIORITY", "3")
+
+	// errors of any unit but cron
+	chain := FilterChain{
+		Filters: []Filter{
+			{Name: "_SYSTEMD_UNIT", Matches: []string{"cron.service"}},
+			{Name: "PRIORITY", Matches: []string{"3"}, Keep: true, Operator: FILTER_LESS_EQUAL},
+		},
+	}
+	assert.True(t, chain.filterIn(log))
+
+	// the same with ssh excluded as an alternative of unknown priority
+	chain.FilterChains = []FilterChain{{
+		OperatorOr: true,
+		Filters: []Filter{
+			{Name: "_SYSTEMD_UNIT", Matches: []string{"ssh.service"}},
+			{Name: "PRIORITY", Keep: true, Operator: FILTER_ABSENT},
+		},
+	}}
+	assert.False(t, chain.filterIn(log))
+}
+
+func TestFilterValidate(t *testing.T) {
+	testCases := []struct {
+		name          string
+		filter        Filter
+		expectedError bool
+	}{
+		{
+			name:   "equal",
+			filter: Filter{Name: "_PID", Matches: []string{"1"}},
+		},
+		{
+			name:   "exists without matches",
+			filter: Filter{Name: "_PID", Operator: FILTER_EXISTS},
+		},
+		{
+			name:          "without name",
+			filter:        Filter{Matches: []string{"1"}},
+			expectedError: true,
+		},
+		{
+			name:          "without matches",
+			filter:        Filter{Name: "_PID"},
+			expectedError: true,
+		},
+		{
+			name:          "invalid regular expression",
+			filter:        Filter{Name: "MESSAGE", Matches: []string{"("}, Operator: FILTER_REGEX},
+			expectedError: true,
+		},
+		{
+			name:          "invalid glob",
+			filter:        Filter{Name: "MESSAGE", Matches: []string{"["}, Operator: FILTER_GLOB},
+			expectedError: true,
+		},
+		{
+			name:          "not a number",
+			filter:        Filter{Name: "_PID", Matches: []string{"one"}, Operator: FILTER_GREATER},
+			expectedError: true,
+		},
+		{
+			name:          "unknown operator",
+			filter:        Filter{Name: "_PID", Matches: []string{"1"}, Operator: 100},
+			expectedError: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FilterChain{FilterChains: []FilterChain{{Filters: []Filter{tt.filter}}}}.Validate()
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFilterChainEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "system.journal")
+	writer, err := NewWriter(path, WriterConfig{})
+	require.NoError(t, err)
+	for i, pid := range []string{"1", "20", "300", "4000"} {
+		payloads := [][]byte{[]byte("MESSAGE=message " + pid), []byte("_PID=" + pid)}
+		if i%2 == 0 {
+			payloads = append(payloads, []byte("TAG=even"))
+		}
+		require.NoError(t, writer.writeEntry(uint64(i+1), uint64(i+1), testBootID, payloads))
+	}
+	require.NoError(t, writer.Close())
+
+	reader, err := NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	testCases := []struct {
+		name     string
+		chain    FilterChain
+		expected []string
+		all      bool
+	}{
+		{
+			name:     "equal",
+			chain:    FilterChain{Filters: []Filter{{Name: "_PID", Matches: []string{"20", "4000"}, Keep: true}}},
+			expected: []string{"20", "4000"},
+		},
+		{
+			name:     "numeric comparison",
+			chain:    FilterChain{Filters: []Filter{{Name: "_PID", Matches: []string{"100"}, Keep: true, Operator: FILTER_GREATER}}},
+			expected: []string{"300", "4000"},
+		},
+		{
+			name:     "exists",
+			chain:    FilterChain{Filters: []Filter{{Name: "TAG", Keep: true, Operator: FILTER_EXISTS}}},
+			expected: []string{"1", "300"},
+		},
+		{
+			name:     "attribute",
+			chain:    FilterChain{Filters: []Filter{{Name: ATTRIBUTE_REALTIME_TIMESTAMP, Matches: []string{"2"}, Keep: true, Operator: FILTER_GREATER}}},
+			expected: []string{"300", "4000"},
+			all:      true,
+		},
+		{
+			name: "exclusion narrowed by other filter",
+			chain: FilterChain{Filters: []Filter{
+				{Name: "TAG", Keep: true, Operator: FILTER_EXISTS},
+				{Name: "_PID", Matches: []string{"1"}},
+			}},
+			expected: []string{"300"},
+		},
+		{
+			name: "exclusion as alternative",
+			chain: FilterChain{OperatorOr: true, Filters: []Filter{
+				{Name: "_PID", Matches: []string{"1"}, Keep: true},
+				{Name: "TAG", Keep: true, Operator: FILTER_ABSENT},
+			}},
+			expected: []string{"1", "20", "4000"},
+			all:      true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			offsets, all, err := tt.chain.entries(reader)
+			require.NoError(t, err)
+			assert.Equal(t, tt.all, all)
+
+			// index only narrows the entries, so the filter decides
+			pids := []string{}
+			if !all {
+				for _, offset := range offsets {
+					entry, err := reader.getEntry(offset)
+					require.NoError(t, err)
+					log, err := reader.readData(entry)
+					require.NoError(t, err)
+					if tt.chain.filterIn(log) {
+						pid, _ := log.Get("_PID")
+						pids = append(pids, pid)
+					}
+				}
+				assert.Equal(t, tt.expected, pids)
+			}
+
+			// the same entries are returned by Next
+			reader.SeekHead()
+			reader.SetFilter(&tt.chain)
+			pids = []string{}
+			for {
+				log, err := reader.Next()
+				if err != nil {
+					require.ErrorIs(t, err, io.EOF)
+					break
+				}
+				pid, _ := log.Get("_PID")
+				pids = append(pids, pid)
+			}
+			assert.Equal(t, tt.expected, pids)
+		})
+	}
+}
+
+func TestMatchingDataEntries(t *testing.T) {
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{}, 20))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	testCases := []struct {
+		name     string
+		field    string
+		values   []string
+		expected []uint64
+	}{
+		{
+			name:     "single value",
+			field:    "_PID",
+			values:   []string{"3"},
+			expected: []uint64{4, 11, 18},
+		},
+		{
+			name:     "several values",
+			field:    "_PID",
+			values:   []string{"3", "5"},
+			expected: []uint64{4, 6, 11, 13, 18, 20},
+		},
+		{
+			name:     "missing value",
+			field:    "_PID",
+			values:   []string{"7", "3"},
+			expected: []uint64{4, 11, 18},
+		},
+		{
+			name:     "missing field",
+			field:    "_COMM",
+			values:   []string{"sshd"},
+			expected: []uint64{},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			offsets, err := reader.matchingDataEntries(tt.field, tt.values)
+			require.NoError(t, err)
+
+			seqnums := []uint64{}
+			for _, offset := range offsets {
+				entry, err := reader.getEntry(offset)
+				require.NoError(t, err)
+				seqnums = append(seqnums, entry.seqnum)
+			}
+			slices.Sort(seqnums)
+			assert.Equal(t, tt.expected, seqnums)
+		})
+	}
+}
+
+func TestIntersectOffsets(t *testing.T) {
+	testCases := []struct {
+		name     string
+		a        []uint64
+		b        []uint64
+		expected []uint64
+	}{
+		{
+			name:     "common offsets",
+			a:        []uint64{16, 32, 48, 64},
+			b:        []uint64{8, 32, 64, 128},
+			expected: []uint64{32, 64},
+		},
+		{
+			name:     "disjoint",
+			a:        []uint64{16, 48},
+			b:        []uint64{32, 64},
+			expected: []uint64{},
+		},
+		{
+			name:     "empty",
+			a:        []uint64{16},
+			b:        nil,
+			expected: []uint64{},
+		},
+		{
+			name:     "same",
+			a:        []uint64{16, 32},
+			b:        []uint64{16, 32},
+			expected: []uint64{16, 32},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, intersectOffsets(tt.a, tt.b))
+			assert.Equal(t, tt.expected, intersectOffsets(tt.b, tt.a))
+		})
+	}
+}
+
+func TestFilterIndex(t *testing.T) {
+	path := writeTestJournal(t, WriterConfig{}, 20)
+
+	// entry not matching the filter cannot be read, so it has to be skipped using the index
+	offset := dataOffset(t, path, 6, "MESSAGE=message 5")
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = file.WriteAt([]byte{OBJECT_COMPRESSED_XZ}, int64(offset)+1)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	filter := &FilterChain{Filters: []Filter{{Name: "_PID", Matches: []string{"3"}, Keep: true}}}
+
+	reader, err := NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	reader.SetFilter(filter)
+	assert.Equal(t, testMessages(3, 10, 17), readMessages(t, reader.Next))
+	assert.Equal(t, testMessages(17, 10, 3), readMessages(t, reader.Previous))
+
+	// the entry is read without the filter
+	reader.SetFilter(nil)
+	reader.SeekHead()
+	_, err = reader.Next()
+	require.NoError(t, err)
+	require.NoError(t, reader.SeekSeqnum(6))
+	_, err = reader.Next()
+	assert.Error(t, err)
+
+	// merged reader uses the index as well
+	reader, err = NewReader(path)
+	require.NoError(t, err)
+	merged := NewMergedReader(reader)
+	defer merged.Close()
+	merged.SetFilter(filter)
+	merged.SetErrorHandler(ErrorPolicies{ERROR_CORRUPT: POLICY_ABORT}, nil)
+	assert.Equal(t, testMessages(3, 10, 17), readMessages(t, merged.Next))
+}
+
+func TestFilterIndexGrowingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "system.journal")
+	writer, err := NewWriter(path, WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{1, 2, 3}, []string{"a", "b", "a"})
+
+	reader, err := NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+	reader.SetFilter(&FilterChain{Filters: []Filter{{Name: "MESSAGE", Matches: []string{"a"}, Keep: true}}})
+	assert.Equal(t, []string{"a", "a"}, readMessages(t, reader.Next))
+
+	// entries appended after the index has been built are read as well
+	writeMessages(t, writer, []uint64{4, 5, 6}, []string{"b", "a", "b"})
+	assert.Equal(t, []string{"a"}, readMessages(t, reader.Next))
+	assert.Equal(t, []string{"a", "a", "a"}, readMessages(t, reader.Previous))
+}
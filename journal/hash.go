@@ -0,0 +1,148 @@
+package journal
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// This implementation base on http://burtleburtle.net/bob/c/lookup3.c,
+// https://www.aumasson.jp/siphash/siphash.pdf
+// and on https://github.com/systemd/systemd/blob/main/src/basic/siphash24.c
+
+// jenkinsMix mixes three 32-bit values reversibly
+func jenkinsMix(a, b, c uint32) (uint32, uint32, uint32) {
+	a -= c
+	a ^= bits.RotateLeft32(c, 4)
+	c += b
+	b -= a
+	b ^= bits.RotateLeft32(a, 6)
+	a += c
+	c -= b
+	c ^= bits.RotateLeft32(b, 8)
+	b += a
+	a -= c
+	a ^= bits.RotateLeft32(c, 16)
+	c += b
+	b -= a
+	b ^= bits.RotateLeft32(a, 19)
+	a += c
+	c -= b
+	c ^= bits.RotateLeft32(b, 4)
+	b += a
+	return a, b, c
+}
+
+// jenkinsFinal does final mixing of three 32-bit values into c
+func jenkinsFinal(a, b, c uint32) (uint32, uint32, uint32) {
+	c ^= b
+	c -= bits.RotateLeft32(b, 14)
+	a ^= c
+	a -= bits.RotateLeft32(c, 11)
+	b ^= a
+	b -= bits.RotateLeft32(a, 25)
+	c ^= b
+	c -= bits.RotateLeft32(b, 16)
+	a ^= c
+	a -= bits.RotateLeft32(c, 4)
+	b ^= a
+	b -= bits.RotateLeft32(a, 14)
+	c ^= b
+	c -= bits.RotateLeft32(b, 24)
+	return a, b, c
+}
+
+// jenkinsHashLittle2 is port of hashlittle2 from lookup3.c
+// it returns two 32-bit hash values (primary c and secondary b)
+func jenkinsHashLittle2(data []byte, pc, pb uint32) (uint32, uint32) {
+	a := 0xdeadbeef + uint32(len(data)) + pc
+	b := a
+	c := a + pb
+
+	for len(data) > 12 {
+		a += binary.LittleEndian.Uint32(data[0:4])
+		b += binary.LittleEndian.Uint32(data[4:8])
+		c += binary.LittleEndian.Uint32(data[8:12])
+		a, b, c = jenkinsMix(a, b, c)
+		data = data[12:]
+	}
+
+	// nothing left to add
+	if len(data) == 0 {
+		return c, b
+	}
+
+	// last block is zero-padded to 12 bytes
+	tail := [12]byte{}
+	copy(tail[:], data)
+	a += binary.LittleEndian.Uint32(tail[0:4])
+	b += binary.LittleEndian.Uint32(tail[4:8])
+	c += binary.LittleEndian.Uint32(tail[8:12])
+	_, b, c = jenkinsFinal(a, b, c)
+
+	return c, b
+}
+
+// jenkinsHash64 returns 64-bit Jenkins lookup3 hash used by journal files without keyed hash
+func jenkinsHash64(data []byte) uint64 {
+	c, b := jenkinsHashLittle2(data, 0, 0)
+	return uint64(c)<<32 | uint64(b)
+}
+
+// sipRound is single SipRound transformation of the internal state
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
+
+// siphash24 returns SipHash-2-4 of the data for the given 128-bit key
+// journal files with keyed hash use file_id as the key
+func siphash24(key [16]byte, data []byte) uint64 {
+	k0 := binary.LittleEndian.Uint64(key[0:8])
+	k1 := binary.LittleEndian.Uint64(key[8:16])
+
+	v0 := k0 ^ 0x736f6d6570736575
+	v1 := k1 ^ 0x646f72616e646f6d
+	v2 := k0 ^ 0x6c7967656e657261
+	v3 := k1 ^ 0x7465646279746573
+
+	// last block contains length of the data in the most significant byte
+	last := uint64(len(data)) << 56
+
+	for len(data) >= 8 {
+		m := binary.LittleEndian.Uint64(data[0:8])
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+		data = data[8:]
+	}
+
+	tail := [8]byte{}
+	copy(tail[:], data)
+	last |= binary.LittleEndian.Uint64(tail[:])
+
+	v3 ^= last
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= last
+
+	v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+
+	return v0 ^ v1 ^ v2 ^ v3
+}
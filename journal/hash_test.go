@@ -0,0 +1,137 @@
+package journal
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJenkinsHashLittle2(t *testing.T) {
+	testCases := []struct {
+		name      string
+		data      string
+		pc        uint32
+		pb        uint32
+		expectedC uint32
+		expectedB uint32
+	}{
+		{
+			name:      "empty",
+			data:      "",
+			expectedC: 0xdeadbeef,
+			expectedB: 0xdeadbeef,
+		},
+		{
+			name:      "empty with secondary seed",
+			data:      "",
+			pb:        0xdeadbeef,
+			expectedC: 0xbd5b7dde,
+			expectedB: 0xdeadbeef,
+		},
+		{
+			name:      "four score",
+			data:      "Four score and seven years ago",
+			expectedC: 0x17770551,
+			expectedB: 0xce7226e6,
+		},
+		{
+			name:      "four score with secondary seed",
+			data:      "Four score and seven years ago",
+			pb:        1,
+			expectedC: 0xe3607cae,
+			expectedB: 0xbd371de4,
+		},
+		{
+			name:      "four score with primary seed",
+			data:      "Four score and seven years ago",
+			pc:        1,
+			expectedC: 0xcd628161,
+			expectedB: 0x6cbea4b3,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c, b := jenkinsHashLittle2([]byte(tt.data), tt.pc, tt.pb)
+			assert.Equal(t, tt.expectedC, c)
+			assert.Equal(t, tt.expectedB, b)
+		})
+	}
+}
+
+func TestJenkinsHash64(t *testing.T) {
+	assert.Equal(t, uint64(0x17770551ce7226e6), jenkinsHash64([]byte("Four score and seven years ago")))
+}
+
+func TestSiphash24(t *testing.T) {
+	testCases := []struct {
+		name     string
+		key      string
+		data     string
+		expected uint64
+	}{
+		{
+			name:     "empty",
+			key:      "000102030405060708090a0b0c0d0e0f",
+			data:     "",
+			expected: 0x726fdb47dd0e0e31,
+		},
+		{
+			name:     "15 bytes",
+			key:      "000102030405060708090a0b0c0d0e0f",
+			data:     "000102030405060708090a0b0c0d0e",
+			expected: 0xa129ca6149be45e5,
+		},
+		{
+			name:     "journal data object",
+			key:      "69e0bc24292040569344cea3ad97204c",
+			data:     hex.EncodeToString([]byte("_SOURCE_REALTIME_TIMESTAMP=1713948788416153")),
+			expected: 11256549498076772478,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := hex.DecodeString(tt.key)
+			require.NoError(t, err)
+			data, err := hex.DecodeString(tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, siphash24(([16]byte)(key), data))
+		})
+	}
+}
+
+func TestHeaderHash(t *testing.T) {
+	fileID := [16]byte{0x69, 0xe0, 0xbc, 0x24, 0x29, 0x20, 0x40, 0x56, 0x93, 0x44, 0xce, 0xa3, 0xad, 0x97, 0x20, 0x4c}
+	payload := []byte("_SOURCE_REALTIME_TIMESTAMP=1713948788416153")
+
+	testCases := []struct {
+		name     string
+		header   *Header
+		expected uint64
+	}{
+		{
+			name: "keyed hash",
+			header: &Header{
+				incompatible_flags: HEADER_INCOMPATIBLE_KEYED_HASH,
+				file_id:            fileID,
+			},
+			expected: 11256549498076772478,
+		},
+		{
+			name: "jenkins hash",
+			header: &Header{
+				file_id: fileID,
+			},
+			expected: jenkinsHash64(payload),
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.header.hash(payload))
+		})
+	}
+}
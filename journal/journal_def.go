@@ -1,9 +1,13 @@
-package main
+package journal
 
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"sync"
 
 	"github.com/klauspost/compress/zstd"
 	"github.com/pierrec/lz4"
@@ -20,6 +24,10 @@ const (
 	HEADER_INCOMPATIBLE_COMPRESSED_ZSTD = 1 << 3
 	HEADER_INCOMPATIBLE_COMPACT         = 1 << 4
 
+	// files with other incompatible_flags cannot be read
+	HEADER_INCOMPATIBLE_SUPPORTED = HEADER_INCOMPATIBLE_COMPRESSED_XZ | HEADER_INCOMPATIBLE_COMPRESSED_LZ4 |
+		HEADER_INCOMPATIBLE_KEYED_HASH | HEADER_INCOMPATIBLE_COMPRESSED_ZSTD | HEADER_INCOMPATIBLE_COMPACT
+
 	// Definitions for Header compatible_flags
 	HEADER_COMPATIBLE_SEALED             = 1 << 0
 	HEADER_COMPATIBLE_TAIL_ENTRY_BOOT_ID = 1 << 1
@@ -34,15 +42,20 @@ const (
 	OBJECT_COMPRESSED_LZ4  = 1 << 1
 	OBJECT_COMPRESSED_ZSTD = 1 << 2
 
+	OBJECT_COMPRESSION_MASK = OBJECT_COMPRESSED_XZ | OBJECT_COMPRESSED_LZ4 | OBJECT_COMPRESSED_ZSTD
+
 	// Maximum size for Header
 	// uint8_t -> 1
 	// le32_t -> 4
 	// sd_id128_t -> 16*1
 	// le64_t -> 8
-	HEADER_MAX_SIZE = 16*1 + 2*4 + 4*16*1 + 24*8
+	HEADER_MAX_SIZE = 16*1 + 4*4 + 4*16*1 + 22*8
 
 	TAG_LENGTH = 256 / 8
 
+	// Default maximum size of decompressed Data payload
+	DEFAULT_MAX_DATA_SIZE = 64 * 1024 * 1024
+
 	// Definitions for object types
 	OBJECT_UNUSED           = 0
 	OBJECT_DATA             = 1
@@ -58,6 +71,10 @@ const (
 	// le64_t -> 8
 	OBJECT_HEADER_SIZE = 8*1 + 1*8
 
+	// HashItem size
+	// le64_t -> 8
+	HASH_ITEM_SIZE = 2 * 8
+
 	ATTRIBUTE_CURSOR              = "__CURSOR"
 	ATTRIBUTE_REALTIME_TIMESTAMP  = "__REALTIME_TIMESTAMP"
 	ATTRIBUTE_MONOTONIC_TIMESTAMP = "__MONOTONIC_TIMESTAMP"
@@ -70,7 +87,6 @@ const (
 	PRIORITY_NOTICE    = "notice"
 	PRIORITY_INFO      = "info"
 	PRIORITY_DEBUG     = "debug"
-	PRIORITY_
 )
 
 var PRIORITIES = [8]string{
@@ -84,7 +100,18 @@ var PRIORITIES = [8]string{
 	PRIORITY_DEBUG,
 }
 
+// SYSLOG_FACILITY names by their numbers, the same as used by journalctl --facility
+var FACILITIES = [24]string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "", "", "", "",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// priorityID returns level of the priority given by name or number
 func priorityID(priority string) (int, error) {
+	if id, err := strconv.Atoi(priority); err == nil && id >= 0 && id < len(PRIORITIES) {
+		return id, nil
+	}
 	for id, p := range PRIORITIES {
 		if p == priority {
 			return id, nil
@@ -93,6 +120,19 @@ func priorityID(priority string) (int, error) {
 	return 0, fmt.Errorf("unknown priority name: %s", priority)
 }
 
+// facilityID returns number of the facility given by name or number
+func facilityID(facility string) (int, error) {
+	if id, err := strconv.Atoi(facility); err == nil && id >= 0 && id < len(FACILITIES) {
+		return id, nil
+	}
+	for id, f := range FACILITIES {
+		if f != "" && f == facility {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown facility name: %s", facility)
+}
+
 // le32 converts [4]byte to uint32
 func le32(data [4]byte) uint32 {
 	return binary.LittleEndian.Uint32(data[:])
@@ -103,6 +143,11 @@ func le64(data [8]byte) uint64 {
 	return binary.LittleEndian.Uint64(data[:])
 }
 
+// align64 rounds size up to the multiple of 8, as objects are 64-bit aligned
+func align64(size uint64) uint64 {
+	return (size + 7) &^ 7
+}
+
 // Definition of Header type
 // rel: https://systemd.io/JOURNAL_FILE_FORMAT/#header
 type Header struct {
@@ -140,8 +185,8 @@ type Header struct {
 	data_hash_chain_depth  uint64 // le64_t
 	field_hash_chain_depth uint64 // le64_t
 	// /* Added in 252 */
-	tail_entry_array_offset    uint64 // le64_t
-	tail_entry_array_n_entries uint64 // le64_t
+	tail_entry_array_offset    uint32 // le32_t
+	tail_entry_array_n_entries uint32 // le32_t
 	// /* Added in 254 */
 	tail_entry_offset uint64 // le64_t
 }
@@ -149,7 +194,7 @@ type Header struct {
 // newHeader creates Header out of byte array
 func newHeader(data []byte) (*Header, error) {
 	if len(data) < 208 {
-		return nil, fmt.Errorf("not enough data (%d) to read the header", len(data))
+		return nil, fmt.Errorf("not enough data (%d) to read the header: %w", len(data), io.ErrUnexpectedEOF)
 	}
 	hu := Header{
 		signature:               ([8]byte)(data[0:8]),
@@ -181,7 +226,7 @@ func newHeader(data []byte) (*Header, error) {
 	// Added in 187
 	if hu.header_size > 208 {
 		if len(data) < 224 {
-			return nil, fmt.Errorf("not enough data (%d) to read the header", len(data))
+			return nil, fmt.Errorf("not enough data (%d) to read the header: %w", len(data), io.ErrUnexpectedEOF)
 		}
 		hu.n_data = le64(([8]byte)(data[208:216]))
 		hu.n_fields = le64(([8]byte)(data[216:224]))
@@ -190,7 +235,7 @@ func newHeader(data []byte) (*Header, error) {
 	// Added in 189
 	if hu.header_size > 224 {
 		if len(data) < 240 {
-			return nil, fmt.Errorf("not enough data (%d) to read the header", len(data))
+			return nil, fmt.Errorf("not enough data (%d) to read the header: %w", len(data), io.ErrUnexpectedEOF)
 		}
 		hu.n_tags = le64(([8]byte)(data[224:232]))
 		hu.n_entry_arrays = le64(([8]byte)(data[232:240]))
@@ -199,7 +244,7 @@ func newHeader(data []byte) (*Header, error) {
 	// Added in 246
 	if hu.header_size > 240 {
 		if len(data) < 256 {
-			return nil, fmt.Errorf("not enough data (%d) to read the header", len(data))
+			return nil, fmt.Errorf("not enough data (%d) to read the header: %w", len(data), io.ErrUnexpectedEOF)
 		}
 		hu.data_hash_chain_depth = le64(([8]byte)(data[240:248]))
 		hu.field_hash_chain_depth = le64(([8]byte)(data[248:256]))
@@ -207,29 +252,90 @@ func newHeader(data []byte) (*Header, error) {
 
 	// Added in 252
 	if hu.header_size > 256 {
-		if len(data) < 272 {
-			return nil, fmt.Errorf("not enough data (%d) to read the header", len(data))
+		if len(data) < 264 {
+			return nil, fmt.Errorf("not enough data (%d) to read the header: %w", len(data), io.ErrUnexpectedEOF)
 		}
-		hu.tail_entry_array_offset = le64(([8]byte)(data[256:264]))
-		hu.tail_entry_array_n_entries = le64(([8]byte)(data[264:272]))
+		hu.tail_entry_array_offset = le32(([4]byte)(data[256:260]))
+		hu.tail_entry_array_n_entries = le32(([4]byte)(data[260:264]))
 	}
 
 	// Added in 254
-	if hu.header_size > 272 {
+	if hu.header_size > 264 {
 		if len(data) < HEADER_MAX_SIZE {
-			return nil, fmt.Errorf("not enough data (%d) to read the header", len(data))
+			return nil, fmt.Errorf("not enough data (%d) to read the header: %w", len(data), io.ErrUnexpectedEOF)
 		}
-		hu.tail_entry_offset = le64(([8]byte)(data[272:280]))
+		hu.tail_entry_offset = le64(([8]byte)(data[264:272]))
 	}
 
 	return &hu, nil
 }
 
+// bytes returns Header encoded in the file format
+// all fields up to tail_entry_offset are encoded, so header_size should be HEADER_MAX_SIZE
+func (hu Header) bytes() []byte {
+	data := make([]byte, 0, HEADER_MAX_SIZE)
+	data = append(data, hu.signature[:]...)
+	data = binary.LittleEndian.AppendUint32(data, hu.compatible_flags)
+	data = binary.LittleEndian.AppendUint32(data, hu.incompatible_flags)
+	data = append(data, hu.state)
+	data = append(data, hu.reserved[:]...)
+	data = append(data, hu.file_id[:]...)
+	data = append(data, hu.machine_id[:]...)
+	data = append(data, hu.tail_entry_boot_id[:]...)
+	data = append(data, hu.seqnum_id[:]...)
+
+	for _, value := range []uint64{
+		hu.header_size,
+		hu.arena_size,
+		hu.data_hash_table_offset,
+		hu.data_hash_table_size,
+		hu.field_hash_table_offset,
+		hu.field_hash_table_size,
+		hu.tail_object_offset,
+		hu.n_objects,
+		hu.n_entries,
+		hu.tail_entry_seqnum,
+		hu.head_entry_seqnum,
+		hu.entry_array_offset,
+		hu.head_entry_realtime,
+		hu.tail_entry_realtime,
+		hu.tail_entry_monotonic,
+		hu.n_data,
+		hu.n_fields,
+		hu.n_tags,
+		hu.n_entry_arrays,
+		hu.data_hash_chain_depth,
+		hu.field_hash_chain_depth,
+	} {
+		data = binary.LittleEndian.AppendUint64(data, value)
+	}
+
+	data = binary.LittleEndian.AppendUint32(data, hu.tail_entry_array_offset)
+	data = binary.LittleEndian.AppendUint32(data, hu.tail_entry_array_n_entries)
+	data = binary.LittleEndian.AppendUint64(data, hu.tail_entry_offset)
+
+	return data
+}
+
 // isCompact returns true if HEADER_INCOMPATIBLE_COMPACT flag is enable
 func (hu Header) isCompact() bool {
 	return hu.incompatible_flags&HEADER_INCOMPATIBLE_COMPACT > 0
 }
 
+// isKeyedHash returns true if HEADER_INCOMPATIBLE_KEYED_HASH flag is enable
+func (hu Header) isKeyedHash() bool {
+	return hu.incompatible_flags&HEADER_INCOMPATIBLE_KEYED_HASH > 0
+}
+
+// hash computes hash of the payload in the way used by the file hash tables
+// siphash24 keyed by file_id is used for files with keyed hash, jenkins lookup3 otherwise
+func (hu Header) hash(payload []byte) uint64 {
+	if hu.isKeyedHash() {
+		return siphash24(hu.file_id, payload)
+	}
+	return jenkinsHash64(payload)
+}
+
 // Definition of ObjectHeader type
 // rel: https://systemd.io/JOURNAL_FILE_FORMAT/#objects
 type ObjectHeader struct {
@@ -256,6 +362,14 @@ func newObjectHeader(data []byte) (*ObjectHeader, error) {
 	return &oh, nil
 }
 
+// headerBytes returns ObjectHeader encoded in the file format, without the payload
+func (oh *ObjectHeader) headerBytes() []byte {
+	data := make([]byte, 0, OBJECT_HEADER_SIZE)
+	data = append(data, oh.objectType, oh.flags)
+	data = append(data, oh.reserved[:]...)
+	return binary.LittleEndian.AppendUint64(data, oh.size)
+}
+
 // setPayload sets payload for ObjectHeader
 func (oh *ObjectHeader) setPayload(payload []byte) {
 	oh.payload = payload
@@ -266,8 +380,22 @@ func (oh *ObjectHeader) payloadSize() int {
 	return int(oh.size) - OBJECT_HEADER_SIZE
 }
 
+// invalidPayloadSize returns error for the object which payload doesn't match its layout
+func invalidPayloadSize(object string, size int) error {
+	return fmt.Errorf("%s object has invalid payload size (%d)", object, size)
+}
+
 // EntryArray returns EntryArray object out of the ObjectHeader object
-func (oh *ObjectHeader) EntryArray(incompatible_compact bool) *EntryArray {
+// it returns error if the payload is too short or it isn't aligned to the items
+func (oh *ObjectHeader) EntryArray(incompatible_compact bool) (*EntryArray, error) {
+	itemSize := 8
+	if incompatible_compact {
+		itemSize = 4
+	}
+	if len(oh.payload) < 8 || (len(oh.payload)-8)%itemSize != 0 {
+		return nil, invalidPayloadSize("entry array", len(oh.payload))
+	}
+
 	do := EntryArray{
 		ObjectHeader: oh,
 
@@ -301,11 +429,20 @@ func (oh *ObjectHeader) EntryArray(incompatible_compact bool) *EntryArray {
 	do.compactItems = compactItems
 	do.regularItems = regularItems
 
-	return &do
+	return &do, nil
 }
 
 // Entry returns Entry object out of the ObjectHeader object
-func (oh *ObjectHeader) Entry(incompatible_compact bool) *Entry {
+// it returns error if the payload is too short or it isn't aligned to the items
+func (oh *ObjectHeader) Entry(incompatible_compact bool) (*Entry, error) {
+	itemSize := 16
+	if incompatible_compact {
+		itemSize = 4
+	}
+	if len(oh.payload) < 48 || (len(oh.payload)-48)%itemSize != 0 {
+		return nil, invalidPayloadSize("entry", len(oh.payload))
+	}
+
 	eo := Entry{
 		ObjectHeader: oh,
 
@@ -346,11 +483,16 @@ func (oh *ObjectHeader) Entry(incompatible_compact bool) *Entry {
 	eo.itemsRegular = regularItems
 	eo.itemsCompact = compactItems
 
-	return &eo
+	return &eo, nil
 }
 
 // Data returns Data object out of the ObjectHeader object
-func (oh *ObjectHeader) Data(incompatibleCompact bool) *Data {
+// it returns error if the payload is too short
+func (oh *ObjectHeader) Data(incompatibleCompact bool) (*Data, error) {
+	if len(oh.payload) < 48 || (incompatibleCompact && len(oh.payload) < 56) {
+		return nil, invalidPayloadSize("data", len(oh.payload))
+	}
+
 	do := Data{
 		ObjectHeader:       oh,
 		hash:               le64(([8]byte)(oh.payload[0:8])),
@@ -369,11 +511,27 @@ func (oh *ObjectHeader) Data(incompatibleCompact bool) *Data {
 		do.payload = oh.payload[48:]
 	}
 
-	return &do
+	return &do, nil
 }
 
 // DataHashTable returns HashTable object out of the ObjectHeader object
-func (oh *ObjectHeader) DataHashTable() *HashTable {
+func (oh *ObjectHeader) DataHashTable() (*HashTable, error) {
+	return oh.hashTable()
+}
+
+// FieldHashTable returns HashTable object out of the ObjectHeader object
+func (oh *ObjectHeader) FieldHashTable() (*HashTable, error) {
+	return oh.hashTable()
+}
+
+// hashTable returns HashTable object out of the ObjectHeader object
+// data and field hash tables share the same layout
+// it returns error if the payload isn't aligned to the items
+func (oh *ObjectHeader) hashTable() (*HashTable, error) {
+	if len(oh.payload)%HASH_ITEM_SIZE != 0 {
+		return nil, invalidPayloadSize("hash table", len(oh.payload))
+	}
+
 	hashItems := []HashItem{}
 
 	ht := HashTable{
@@ -394,7 +552,38 @@ func (oh *ObjectHeader) DataHashTable() *HashTable {
 
 	ht.items = hashItems
 
-	return &ht
+	return &ht, nil
+}
+
+// Field returns Field object out of the ObjectHeader object
+// it returns error if the payload is too short
+func (oh *ObjectHeader) Field() (*Field, error) {
+	if len(oh.payload) < 24 {
+		return nil, invalidPayloadSize("field", len(oh.payload))
+	}
+
+	return &Field{
+		ObjectHeader:     oh,
+		hash:             le64(([8]byte)(oh.payload[0:8])),
+		next_hash_offset: le64(([8]byte)(oh.payload[8:16])),
+		head_data_offset: le64(([8]byte)(oh.payload[16:24])),
+		payload:          oh.payload[24:],
+	}, nil
+}
+
+// Tag returns Tag object out of the ObjectHeader object
+// it returns error if the payload doesn't match the size of the tag
+func (oh *ObjectHeader) Tag() (*Tag, error) {
+	if len(oh.payload) != 16+TAG_LENGTH {
+		return nil, invalidPayloadSize("tag", len(oh.payload))
+	}
+
+	return &Tag{
+		ObjectHeader: oh,
+		seqnum:       le64(([8]byte)(oh.payload[0:8])),
+		epoch:        le64(([8]byte)(oh.payload[8:16])),
+		tag:          ([TAG_LENGTH]byte)(oh.payload[16 : 16+TAG_LENGTH]),
+	}, nil
 }
 
 // definition of Data type
@@ -416,49 +605,121 @@ type Data struct {
 	payload []uint8 // uint8_t[]
 }
 
-// getPayloadKeyValue returns payload as key and value strings
-// it handles compressed payload
-func (so Data) getPayloadKeyValue() (string, string, error) {
+// getPayload returns payload of the Data object
+// it handles compressed payload which cannot be bigger than maxSize after decompression
+func (so Data) getPayload(maxSize int) ([]uint8, error) {
 	var payload []uint8
 
+	if so.flags&^OBJECT_COMPRESSION_MASK != 0 {
+		return nil, fmt.Errorf("data object has unknown flags (%d): %w", so.flags, errors.ErrUnsupported)
+	}
+
 	switch true {
 	case so.flags&OBJECT_COMPRESSED_XZ > 0:
 		// decompress xz payload
 		r, err := xz.NewReader(bytes.NewReader(so.payload))
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
-		_, err = r.Read(payload)
+		payload, err = io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 	case so.flags&OBJECT_COMPRESSED_LZ4 > 0:
 		// decompress lz4 payload
-		r := lz4.NewReader(bytes.NewReader(so.payload))
-		_, err := r.Read(payload)
+		// it is raw lz4 block prefixed with le64_t size of decompressed data
+		if len(so.payload) < 8 {
+			return nil, fmt.Errorf("not enough data (%d) to read lz4 payload size", len(so.payload))
+		}
+		size := le64(([8]byte)(so.payload[0:8]))
+		if size > uint64(maxSize) {
+			return nil, fmt.Errorf("decompressed payload size (%d) exceeds the limit (%d)", size, maxSize)
+		}
+		payload = make([]uint8, size)
+		read, err := lz4.UncompressBlock(so.payload[8:], payload)
 		if err != nil {
-			return "", "", err
+			return nil, err
+		}
+		if uint64(read) != size {
+			return nil, fmt.Errorf("decompressed payload size (%d) doesn't match declared size (%d)", read, size)
 		}
 	case so.flags&OBJECT_COMPRESSED_ZSTD > 0:
 		// decompress zstd payload
-		r, err := zstd.NewReader(bytes.NewReader([]byte{}))
+		r, err := zstdDecoder(maxSize)
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 		payload, err = r.DecodeAll(so.payload, nil)
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 	default:
 		payload = so.payload
 	}
 
+	if len(payload) > maxSize {
+		return nil, fmt.Errorf("decompressed payload size exceeds the limit (%d)", maxSize)
+	}
+
+	return payload, nil
+}
+
+// zstdDecoders keeps zstd decoders per maximum decompressed size
+var zstdDecoders sync.Map
+
+// zstdDecoder returns zstd decoder which doesn't decompress more than maxSize
+func zstdDecoder(maxSize int) (*zstd.Decoder, error) {
+	if decoder, ok := zstdDecoders.Load(maxSize); ok {
+		return decoder.(*zstd.Decoder), nil
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)), zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		return nil, err
+	}
+
+	stored, _ := zstdDecoders.LoadOrStore(maxSize, decoder)
+	return stored.(*zstd.Decoder), nil
+}
+
+// getPayloadKeyValue returns payload as key and value strings
+// it handles compressed payload
+// it returns error if the payload has no `=` separating the key
+func (so Data) getPayloadKeyValue(maxSize int) (string, string, error) {
+	payload, err := so.getPayload(maxSize)
+	if err != nil {
+		return "", "", err
+	}
+
 	// Split payload by first `=`
-	for i := 0; ; i++ {
-		if payload[i] == '=' {
-			return string(payload[:i]), string(payload[i+1:]), nil
-		}
+	key, value, found := bytes.Cut(payload, []byte("="))
+	if !found {
+		return "", "", fmt.Errorf("data payload %q has no field name", payload)
 	}
+
+	return string(key), string(value), nil
+}
+
+// definition of Field type
+// rel: https://systemd.io/JOURNAL_FILE_FORMAT/#field-objects
+type Field struct {
+	*ObjectHeader
+
+	hash             uint64 // le64_t
+	next_hash_offset uint64 // le64_t
+	head_data_offset uint64 // le64_t
+
+	payload []uint8 // uint8_t[]
+}
+
+// definition of Tag type
+// rel: https://systemd.io/JOURNAL_FILE_FORMAT/#tag-object
+type Tag struct {
+	*ObjectHeader
+
+	seqnum uint64            // le64_t
+	epoch  uint64            // le64_t
+	tag    [TAG_LENGTH]uint8 // uint8_t[TAG_LENGTH] /* SHA-256 HMAC */
 }
 
 // definition of helper structure for regular items
@@ -512,7 +773,7 @@ type HashItem struct {
 	tail_hash_offset uint64 // le64_t
 }
 
-// definition of DataHashTable type
+// definition of DataHashTable and FieldHashTable type
 // rel: https://systemd.io/JOURNAL_FILE_FORMAT/#hash-table-objects
 type HashTable struct {
 	*ObjectHeader
@@ -548,7 +809,57 @@ func (eao *EntryArray) items() []uint64 {
 	return items
 }
 
-// Log is just a key-value map
+// Log is single journal entry
+// it keeps order of the fields, repeated fields and raw bytes of the values
 type Log struct {
-	attributes map[string]string
+	items []LogField
+}
+
+// LogField is single field of the Log
+type LogField struct {
+	Name  string
+	Value []byte
+}
+
+// Add appends field to the Log
+func (log *Log) Add(name string, value []byte) {
+	log.items = append(log.items, LogField{Name: name, Value: value})
+}
+
+// AddString appends field with text value to the Log
+func (log *Log) AddString(name string, value string) {
+	log.Add(name, []byte(value))
+}
+
+// Fields returns all fields of the Log in the original order
+func (log Log) Fields() []LogField {
+	return log.items
+}
+
+// Values returns all values of the field in the original order
+func (log Log) Values(name string) [][]byte {
+	values := [][]byte{}
+	for _, field := range log.items {
+		if field.Name == name {
+			values = append(values, field.Value)
+		}
+	}
+	return values
+}
+
+// Cursor returns cursor of the entry, see ParseCursor
+func (log Log) Cursor() string {
+	cursor, _ := log.Get(ATTRIBUTE_CURSOR)
+	return cursor
+}
+
+// Get returns the first value of the field as string
+// it is convenient for the fields which are expected to have single text value
+func (log Log) Get(name string) (string, bool) {
+	for _, field := range log.items {
+		if field.Name == name {
+			return string(field.Value), true
+		}
+	}
+	return "", false
 }
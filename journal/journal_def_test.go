@@ -1,7 +1,8 @@
-package main
+package journal
 
 import (
 	"encoding/hex"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -140,6 +141,43 @@ func TestNewHeader(t *testing.T) {
 				tail_entry_offset:       0,
 			},
 		},
+		{
+			name: "header 252 compact",
+			data: "4c504b5348485248000000001c0000000100000000000000a5e508b17aa14116b3994ed83512266cfed6b2924c424cf1b9a322f606b4de6d40edb14898d747048a6dd1175f0974c8a5e508b17aa14116b3994ed83512266c0801000000000000f8fe7f0000000000f81500000000000080e33800000000001801000000000000d014000000000000489a5100000000005f30000000000000bb0b000000000000bb0b0000000000000100000000000000c800390000000000d4496ab3fe5d060098d07cb3fe5d0600e532603b00000000a22300000000000027000000000000000000000000000000d9000000000000000200000000000000000000000000000050a841009f070000",
+			expected: &Header{
+				signature:                  [8]uint8{0x4c, 0x50, 0x4b, 0x53, 0x48, 0x48, 0x52, 0x48},
+				incompatible_flags:         HEADER_INCOMPATIBLE_KEYED_HASH | HEADER_INCOMPATIBLE_COMPRESSED_ZSTD | HEADER_INCOMPATIBLE_COMPACT,
+				state:                      STATE_ONLINE,
+				file_id:                    [16]uint8{0xa5, 0xe5, 0x08, 0xb1, 0x7a, 0xa1, 0x41, 0x16, 0xb3, 0x99, 0x4e, 0xd8, 0x35, 0x12, 0x26, 0x6c},
+				machine_id:                 [16]uint8{0xfe, 0xd6, 0xb2, 0x92, 0x4c, 0x42, 0x4c, 0xf1, 0xb9, 0xa3, 0x22, 0xf6, 0x06, 0xb4, 0xde, 0x6d},
+				tail_entry_boot_id:         [16]uint8{0x40, 0xed, 0xb1, 0x48, 0x98, 0xd7, 0x47, 0x04, 0x8a, 0x6d, 0xd1, 0x17, 0x5f, 0x09, 0x74, 0xc8},
+				seqnum_id:                  [16]uint8{0xa5, 0xe5, 0x08, 0xb1, 0x7a, 0xa1, 0x41, 0x16, 0xb3, 0x99, 0x4e, 0xd8, 0x35, 0x12, 0x26, 0x6c},
+				header_size:                264,
+				arena_size:                 8388344,
+				data_hash_table_offset:     5624,
+				data_hash_table_size:       3728256,
+				field_hash_table_offset:    280,
+				field_hash_table_size:      5328,
+				tail_object_offset:         5347912,
+				n_objects:                  12383,
+				n_entries:                  3003,
+				tail_entry_seqnum:          3003,
+				head_entry_seqnum:          1,
+				entry_array_offset:         3735752,
+				head_entry_realtime:        1792198373427668,
+				tail_entry_realtime:        1792198374641816,
+				tail_entry_monotonic:       996160229,
+				n_data:                     9122,
+				n_fields:                   39,
+				n_tags:                     0,
+				n_entry_arrays:             217,
+				data_hash_chain_depth:      2,
+				field_hash_chain_depth:     0,
+				tail_entry_array_offset:    4302928,
+				tail_entry_array_n_entries: 1951,
+				tail_entry_offset:          0,
+			},
+		},
 	}
 	for _, tt := range testCases {
 		t.Run(tt.name, func(t *testing.T) {
@@ -148,6 +186,11 @@ func TestNewHeader(t *testing.T) {
 			header, err := newHeader(data)
 			require.NoError(t, err)
 			assert.Equal(t, tt.expected, header)
+
+			// encoded header contains all fields, also these not present in the data
+			encoded := header.bytes()
+			assert.Len(t, encoded, HEADER_MAX_SIZE)
+			assert.Equal(t, data, encoded[:len(data)])
 		})
 	}
 }
@@ -262,7 +305,8 @@ func TestData(t *testing.T) {
 			require.NoError(t, err)
 			header := ObjectHeader{}
 			header.setPayload(payload)
-			data := header.Data(false)
+			data, err := header.Data(false)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, data)
 		})
 	}
@@ -320,7 +364,8 @@ func TestEntry(t *testing.T) {
 			require.NoError(t, err)
 			header := ObjectHeader{}
 			header.setPayload(payload)
-			entry := header.Entry(tt.compact)
+			entry, err := header.Entry(tt.compact)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, entry)
 		})
 	}
@@ -512,8 +557,348 @@ func TestEntryArray(t *testing.T) {
 			require.NoError(t, err)
 			header := ObjectHeader{}
 			header.setPayload(payload)
-			entry := header.EntryArray(tt.compact)
+			entry, err := header.EntryArray(tt.compact)
+			require.NoError(t, err)
 			assert.Equal(t, tt.expected, entry)
 		})
 	}
 }
+
+func TestDecodeInvalidPayload(t *testing.T) {
+	testCases := []struct {
+		name    string
+		size    int
+		compact bool
+		decode  func(oh *ObjectHeader, compact bool) error
+	}{
+		{
+			name:   "entry too short",
+			size:   47,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.Entry(compact); return err },
+		},
+		{
+			name:   "entry with misaligned items",
+			size:   48 + 20,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.Entry(compact); return err },
+		},
+		{
+			name:    "compact entry with misaligned items",
+			size:    48 + 6,
+			compact: true,
+			decode:  func(oh *ObjectHeader, compact bool) error { _, err := oh.Entry(compact); return err },
+		},
+		{
+			name:   "entry array too short",
+			size:   4,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.EntryArray(compact); return err },
+		},
+		{
+			name:   "entry array with misaligned items",
+			size:   8 + 12,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.EntryArray(compact); return err },
+		},
+		{
+			name:   "data too short",
+			size:   40,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.Data(compact); return err },
+		},
+		{
+			name:    "compact data too short",
+			size:    50,
+			compact: true,
+			decode:  func(oh *ObjectHeader, compact bool) error { _, err := oh.Data(compact); return err },
+		},
+		{
+			name:   "hash table with misaligned items",
+			size:   HASH_ITEM_SIZE + 8,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.DataHashTable(); return err },
+		},
+		{
+			name:   "field too short",
+			size:   16,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.Field(); return err },
+		},
+		{
+			name:   "tag too short",
+			size:   16 + TAG_LENGTH - 1,
+			decode: func(oh *ObjectHeader, compact bool) error { _, err := oh.Tag(); return err },
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			header := ObjectHeader{}
+			header.setPayload(make([]byte, tt.size))
+			assert.Error(t, tt.decode(&header, tt.compact))
+		})
+	}
+}
+
+func TestGetPayloadKeyValue(t *testing.T) {
+	testCases := []struct {
+		name          string
+		flags         uint8
+		payload       string
+		maxSize       int
+		expectedKey   string
+		expectedValue string
+		expectedError bool
+	}{
+		{
+			name:          "not compressed",
+			payload:       "5f534f555243455f5245414c54494d455f54494d455354414d503d31373133393438373838343136313533",
+			maxSize:       DEFAULT_MAX_DATA_SIZE,
+			expectedKey:   "_SOURCE_REALTIME_TIMESTAMP",
+			expectedValue: "1713948788416153",
+		},
+		{
+			name:          "xz",
+			flags:         OBJECT_COMPRESSED_XZ,
+			payload:       "fd377a585a000000ff12d9410200210116000000742fe5a3e007d400175d00211244f799688649a32842439a329d3f0cfc7b9ce60000000000012bd50f00000057c148b7a8000afc020000000000595a",
+			maxSize:       DEFAULT_MAX_DATA_SIZE,
+			expectedKey:   "BIG",
+			expectedValue: strings.Repeat("x", 2000) + "0",
+		},
+		{
+			name:          "lz4",
+			flags:         OBJECT_COMPRESSED_LZ4,
+			payload:       "d5070000000000005f4249473d780100ffffffffffffffb1000200000200b07878787878787878787830",
+			maxSize:       DEFAULT_MAX_DATA_SIZE,
+			expectedKey:   "BIG",
+			expectedValue: strings.Repeat("x", 2000) + "0",
+		},
+		{
+			name:          "zstd",
+			flags:         OBJECT_COMPRESSED_ZSTD,
+			payload:       "28b52ffd60d5066d0000304249473d78300100ccff400b",
+			maxSize:       DEFAULT_MAX_DATA_SIZE,
+			expectedKey:   "BIG",
+			expectedValue: strings.Repeat("x", 2000) + "0",
+		},
+		{
+			name:          "xz over limit",
+			flags:         OBJECT_COMPRESSED_XZ,
+			payload:       "fd377a585a000000ff12d9410200210116000000742fe5a3e007d400175d00211244f799688649a32842439a329d3f0cfc7b9ce60000000000012bd50f00000057c148b7a8000afc020000000000595a",
+			maxSize:       1024,
+			expectedError: true,
+		},
+		{
+			name:          "lz4 over limit",
+			flags:         OBJECT_COMPRESSED_LZ4,
+			payload:       "d5070000000000005f4249473d780100ffffffffffffffb1000200000200b07878787878787878787830",
+			maxSize:       1024,
+			expectedError: true,
+		},
+		{
+			name:          "zstd over limit",
+			flags:         OBJECT_COMPRESSED_ZSTD,
+			payload:       "28b52ffd60d5066d0000304249473d78300100ccff400b",
+			maxSize:       1024,
+			expectedError: true,
+		},
+		{
+			name:          "without field name",
+			payload:       "4d455353414745",
+			maxSize:       DEFAULT_MAX_DATA_SIZE,
+			expectedError: true,
+		},
+		{
+			name:          "lz4 without size",
+			flags:         OBJECT_COMPRESSED_LZ4,
+			payload:       "d507",
+			maxSize:       DEFAULT_MAX_DATA_SIZE,
+			expectedError: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := hex.DecodeString(tt.payload)
+			require.NoError(t, err)
+			data := Data{
+				ObjectHeader: &ObjectHeader{
+					flags: tt.flags,
+				},
+				payload: payload,
+			}
+			key, value, err := data.getPayloadKeyValue(tt.maxSize)
+			if tt.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedKey, key)
+			assert.Equal(t, tt.expectedValue, value)
+		})
+	}
+}
+
+// testLog returns Log with the fields given as name and value pairs
+func testLog(pairs ...string) Log {
+	log := Log{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		log.AddString(pairs[i], pairs[i+1])
+	}
+	return log
+}
+
+func TestLog(t *testing.T) {
+	log := testLog(
+		"MESSAGE", "first",
+		"TAG", "a",
+		"COREDUMP", "\x7fELF\x00\x01",
+		"TAG", "b",
+	)
+
+	assert.Equal(t, []LogField{
+		{Name: "MESSAGE", Value: []byte("first")},
+		{Name: "TAG", Value: []byte("a")},
+		{Name: "COREDUMP", Value: []byte("\x7fELF\x00\x01")},
+		{Name: "TAG", Value: []byte("b")},
+	}, log.Fields())
+
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, log.Values("TAG"))
+	assert.Empty(t, log.Values("_PID"))
+
+	value, ok := log.Get("TAG")
+	assert.True(t, ok)
+	assert.Equal(t, "a", value)
+
+	value, ok = log.Get("COREDUMP")
+	assert.True(t, ok)
+	assert.Equal(t, "\x7fELF\x00\x01", value)
+
+	_, ok = log.Get("_PID")
+	assert.False(t, ok)
+}
+
+// fuzzObjectHeader returns ObjectHeader with the given payload
+func fuzzObjectHeader(payload []byte) *ObjectHeader {
+	header := ObjectHeader{}
+	header.setPayload(payload)
+	return &header
+}
+
+func FuzzNewHeader(f *testing.F) {
+	f.Add(make([]byte, 208))
+	f.Add(Header{signature: [8]byte([]byte("LPKSHHRH")), header_size: HEADER_MAX_SIZE}.bytes())
+	f.Add(Header{header_size: 256}.bytes()[:240])
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, err := newHeader(data)
+		if err == nil {
+			assert.NotNil(t, header)
+		}
+	})
+}
+
+func FuzzNewObjectHeader(f *testing.F) {
+	f.Add(make([]byte, OBJECT_HEADER_SIZE))
+	f.Add([]byte{OBJECT_DATA, OBJECT_COMPRESSED_ZSTD, 0, 0, 0, 0, 0, 0, 0x40})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, err := newObjectHeader(data)
+		if err == nil {
+			assert.Equal(t, data[:OBJECT_HEADER_SIZE], header.headerBytes())
+		}
+	})
+}
+
+func FuzzEntry(f *testing.F) {
+	f.Add(make([]byte, 48+16), false)
+	f.Add(make([]byte, 48+4), true)
+	f.Add(make([]byte, 50), false)
+
+	f.Fuzz(func(t *testing.T, payload []byte, compact bool) {
+		entry, err := fuzzObjectHeader(payload).Entry(compact)
+		if err != nil {
+			return
+		}
+
+		itemSize := 16
+		if compact {
+			itemSize = 4
+		}
+		assert.Len(t, entry.items(), (len(payload)-48)/itemSize)
+	})
+}
+
+func FuzzEntryArray(f *testing.F) {
+	f.Add(make([]byte, 8+8), false)
+	f.Add(make([]byte, 8+4), true)
+	f.Add(make([]byte, 7), false)
+
+	f.Fuzz(func(t *testing.T, payload []byte, compact bool) {
+		entryArray, err := fuzzObjectHeader(payload).EntryArray(compact)
+		if err != nil {
+			return
+		}
+
+		itemSize := 8
+		if compact {
+			itemSize = 4
+		}
+		assert.Equal(t, (len(payload)-8)/itemSize, entryArray.countItems)
+		assert.Len(t, entryArray.items(), entryArray.countItems)
+	})
+}
+
+func FuzzData(f *testing.F) {
+	payload, err := hex.DecodeString("7eb877f5204c379c000000000000000088443b000000000058473b0000000000000000000000000001000000000000005f534f555243455f5245414c54494d455f54494d455354414d503d31373133393438373838343136313533")
+	require.NoError(f, err)
+	f.Add(payload, uint8(0), false)
+	f.Add(payload, uint8(0), true)
+	f.Add(payload[:48], uint8(OBJECT_COMPRESSED_LZ4), false)
+	f.Add(payload, uint8(OBJECT_COMPRESSED_XZ), false)
+	f.Add(payload, uint8(OBJECT_COMPRESSED_ZSTD), false)
+
+	f.Fuzz(func(t *testing.T, payload []byte, flags uint8, compact bool) {
+		header := fuzzObjectHeader(payload)
+		header.flags = flags
+
+		data, err := header.Data(compact)
+		if err != nil {
+			return
+		}
+
+		// decompressed payload is limited, so the fuzzer cannot exhaust the memory
+		key, _, err := data.getPayloadKeyValue(4096)
+		if err == nil {
+			assert.NotContains(t, key, "=")
+		}
+	})
+}
+
+func FuzzHashTable(f *testing.F) {
+	f.Add(make([]byte, 2*HASH_ITEM_SIZE))
+	f.Add(make([]byte, HASH_ITEM_SIZE+1))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		hashTable, err := fuzzObjectHeader(payload).DataHashTable()
+		if err == nil {
+			assert.Len(t, hashTable.items, len(payload)/HASH_ITEM_SIZE)
+		}
+	})
+}
+
+func FuzzField(f *testing.F) {
+	f.Add(append(make([]byte, 24), []byte("MESSAGE")...))
+	f.Add(make([]byte, 23))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		field, err := fuzzObjectHeader(payload).Field()
+		if err == nil {
+			assert.Equal(t, payload[24:], field.payload)
+		}
+	})
+}
+
+func FuzzTag(f *testing.F) {
+	f.Add(make([]byte, 16+TAG_LENGTH))
+	f.Add(make([]byte, 16))
+
+	f.Fuzz(func(t *testing.T, payload []byte) {
+		tag, err := fuzzObjectHeader(payload).Tag()
+		if err == nil {
+			assert.Equal(t, payload[16:], tag.tag[:])
+		}
+	})
+}
@@ -0,0 +1,351 @@
+package journal
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// This implementation base on ordering of sd_journal_next
+// rel: https://github.com/systemd/systemd/blob/main/src/libsystemd/sd-journal/journal-file.c (journal_file_compare_locations)
+
+// compareEntries returns negative value if entry a is older than entry b, positive if it is newer
+// and zero if they are the same entry
+// seqnum is compared only for files with the same seqnum_id, monotonic time only within the same boot
+// and realtime otherwise, xor_hash orders entries with the same timestamps
+func compareEntries(a *Entry, aSeqnumID [16]byte, b *Entry, bSeqnumID [16]byte) int {
+	if aSeqnumID == bSeqnumID {
+		if c := cmp.Compare(a.seqnum, b.seqnum); c != 0 {
+			return c
+		}
+	}
+
+	if a.boot_id == b.boot_id {
+		if c := cmp.Compare(a.monotonic, b.monotonic); c != 0 {
+			return c
+		}
+	}
+
+	if c := cmp.Compare(a.realtime, b.realtime); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.xor_hash, b.xor_hash)
+}
+
+// mergeHead is the next entry of the file waiting to be merged
+type mergeHead struct {
+	reader *Reader
+
+	// entry is nil if all entries of the file have been read
+	entry    *Entry
+	log      Log
+	seqnumID [16]byte
+
+	// number of transient errors in a row and time of the next retry
+	retries    int
+	retryAfter time.Time
+
+	// file is skipped because of the error
+	failed bool
+}
+
+// fill reads the next entry of the file matching the filter if there is no entry waiting
+// the previous entry is read if reverse is true
+func (head *mergeHead) fill(reverse bool) error {
+	if head.entry != nil {
+		return nil
+	}
+
+	// archived files don't grow, so there is no need to check the header again
+	if head.reader.getHeader().state != STATE_ARCHIVED {
+		if err := head.reader.loadHeader(); err != nil {
+			return err
+		}
+	}
+
+	entry, log, err := head.reader.nextMatchingEntry(reverse)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	head.entry = entry
+	head.log = log
+	head.seqnumID = head.reader.getHeader().seqnum_id
+	return nil
+}
+
+// precedes returns true if the waiting entry goes before the entry of the other head
+// newer entries go first if reverse is true
+func (head *mergeHead) precedes(other *mergeHead, reverse bool) bool {
+	c := compareEntries(head.entry, head.seqnumID, other.entry, other.seqnumID)
+	if reverse {
+		return c > 0
+	}
+	return c < 0
+}
+
+// MergedReader reads entries of many files in chronological order or in the reverse one
+// it keeps the next entry of every file and returns the oldest one, or the newest one if reading is reversed
+// entries stored in more files are returned once
+type MergedReader struct {
+	mu sync.Mutex
+
+	heads  []*mergeHead
+	filter *FilterChain
+
+	// entries are read from the newest to the oldest one
+	reverse bool
+
+	// errors of reading are reported and handled by the handler
+	handler errorHandler
+
+	// readers of the files skipped because of errors, they are not closed
+	failed []*Reader
+
+	// cursor of the last returned entry, used to drop duplicates
+	last *Cursor
+}
+
+// NewMergedReader returns MergedReader reading the files from the current position of the readers
+func NewMergedReader(readers ...*Reader) *MergedReader {
+	mr := &MergedReader{}
+	for _, reader := range readers {
+		mr.Add(reader)
+	}
+	return mr
+}
+
+// Add adds the file to the merged ones, entries are read from the current position of the reader
+func (mr *MergedReader) Add(reader *Reader) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.filter != nil {
+		reader.SetFilter(mr.filter)
+	}
+	mr.heads = append(mr.heads, &mergeHead{reader: reader})
+}
+
+// Remove removes the file from the merged ones, the reader is not closed
+func (mr *MergedReader) Remove(reader *Reader) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.heads = slices.DeleteFunc(mr.heads, func(head *mergeHead) bool {
+		return head.reader == reader
+	})
+}
+
+// removeDrained removes and returns readers of archived files, which have been read completely
+// readers are not closed
+func (mr *MergedReader) removeDrained() []*Reader {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	drained := []*Reader{}
+	heads := []*mergeHead{}
+	for _, head := range mr.heads {
+		// entry waiting to be merged is kept, so only files without more entries are removed
+		if err := head.fill(mr.reverse); err == nil && head.entry == nil && head.reader.getHeader().state == STATE_ARCHIVED {
+			drained = append(drained, head.reader)
+			continue
+		}
+		heads = append(heads, head)
+	}
+	mr.heads = heads
+
+	return drained
+}
+
+// SetErrorHandler sets policies for the errors of reading the files and callback reporting them
+// nil policies mean DefaultErrorPolicies and nil callback disables reporting
+func (mr *MergedReader) SetErrorHandler(policies ErrorPolicies, onError func(err *ReadError)) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.handler = errorHandler{policies: policies, onError: onError}
+}
+
+// removeFailed removes and returns readers of the files skipped because of errors
+// readers are not closed
+func (mr *MergedReader) removeFailed() []*Reader {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	failed := mr.failed
+	mr.failed = nil
+	return failed
+}
+
+// fill reads the next entry of the file and handles errors according to the policies
+// file waiting for retry is not read until RETRY_INTERVAL passes
+// it returns error only if the policy is POLICY_ABORT
+func (mr *MergedReader) fill(head *mergeHead) error {
+	if head.entry == nil && time.Now().Before(head.retryAfter) {
+		return nil
+	}
+
+	skip := head.reader.skipNextEntry
+	if mr.reverse {
+		skip = head.reader.skipPreviousEntry
+	}
+
+	for {
+		err := head.fill(mr.reverse)
+		if err == nil {
+			head.retries = 0
+			return nil
+		}
+
+		policy, readError := mr.handler.handle(head.reader.Name(), err, head.retries, skip)
+		switch policy {
+		case POLICY_RETRY:
+			head.retries++
+			head.retryAfter = time.Now().Add(RETRY_INTERVAL)
+			return nil
+		case POLICY_SKIP_ENTRY:
+			continue
+		case POLICY_SKIP_FILE:
+			head.failed = true
+			return nil
+		default:
+			return readError
+		}
+	}
+}
+
+// removeFailedHeadsLocked moves readers of the files skipped because of errors to the failed ones
+// lock has to be held by the caller
+func (mr *MergedReader) removeFailedHeadsLocked() {
+	mr.heads = slices.DeleteFunc(mr.heads, func(head *mergeHead) bool {
+		if head.failed {
+			mr.failed = append(mr.failed, head.reader)
+		}
+		return head.failed
+	})
+}
+
+// SetFilter limits entries of all files to the ones matching the filter chain
+// nil filter chain disables filtering
+func (mr *MergedReader) SetFilter(filterChain *FilterChain) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.filter = filterChain
+	for _, head := range mr.heads {
+		head.reader.SetFilter(filterChain)
+	}
+}
+
+// SetReverse sets direction of reading, entries are read from the newest to the oldest one if reverse is true
+// readers should be positioned at the end of the files to read all their entries in reverse
+// entries waiting to be merged are returned to their files, so readers are moved back to the same position
+func (mr *MergedReader) SetReverse(reverse bool) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if mr.reverse == reverse {
+		return nil
+	}
+
+	for _, head := range mr.heads {
+		if head.entry == nil {
+			continue
+		}
+
+		// waiting entry is the last one read from the file
+		skip := head.reader.skipPreviousEntry
+		if mr.reverse {
+			skip = head.reader.skipNextEntry
+		}
+		if err := skip(); err != nil {
+			return err
+		}
+		head.entry, head.log = nil, Log{}
+	}
+	mr.reverse = reverse
+
+	return nil
+}
+
+// waiting returns true if any file is waiting for retry of the failed read
+func (mr *MergedReader) waiting() bool {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, head := range mr.heads {
+		if head.entry == nil && head.retries > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Next returns the oldest entry from all the files, or the newest one if reading is reversed
+// it returns io.EOF if there are no more entries, but files are checked again on the next call
+// errors of reading are handled according to the error policies, ReadError is returned for POLICY_ABORT only
+func (mr *MergedReader) Next() (Log, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	defer mr.removeFailedHeadsLocked()
+
+	for {
+		// number of files is small, so the oldest or the newest entry is found by linear search
+		var next *mergeHead
+		for _, head := range mr.heads {
+			if err := mr.fill(head); err != nil {
+				return Log{}, err
+			}
+			if head.failed {
+				continue
+			}
+			if head.entry == nil {
+				continue
+			}
+			if next == nil || head.precedes(next, mr.reverse) {
+				next = head
+			}
+		}
+
+		if next == nil {
+			return Log{}, io.EOF
+		}
+
+		entry, log := next.entry, next.log
+		next.entry, next.log = nil, Log{}
+
+		// the same entry can be stored in more files, e.g. after the file has been copied
+		if mr.last != nil && mr.last.matches(entry, next.seqnumID) {
+			continue
+		}
+
+		cursor := entryCursor(entry, next.seqnumID)
+		mr.last = &cursor
+		return log, nil
+	}
+}
+
+// Close closes all the files, including the ones skipped because of errors
+func (mr *MergedReader) Close() error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	errs := []error{}
+	for _, head := range mr.heads {
+		errs = append(errs, head.reader.Close())
+	}
+	for _, reader := range mr.failed {
+		errs = append(errs, reader.Close())
+	}
+	mr.heads = nil
+	mr.failed = nil
+
+	return errors.Join(errs...)
+}
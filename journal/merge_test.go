@@ -0,0 +1,173 @@
+package journal
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareEntries(t *testing.T) {
+	fileA := [16]byte{0x0a}
+	fileB := [16]byte{0x0b}
+	bootA := [16]byte{0x01}
+	bootB := [16]byte{0x02}
+
+	testCases := []struct {
+		name      string
+		a         Entry
+		aSeqnumID [16]byte
+		b         Entry
+		bSeqnumID [16]byte
+		expected  int
+	}{
+		{
+			name:      "seqnum in the same file",
+			a:         Entry{seqnum: 1, boot_id: bootA, monotonic: 20, realtime: 20},
+			aSeqnumID: fileA,
+			b:         Entry{seqnum: 2, boot_id: bootA, monotonic: 10, realtime: 10},
+			bSeqnumID: fileA,
+			expected:  -1,
+		},
+		{
+			name:      "monotonic in the same boot",
+			a:         Entry{seqnum: 1, boot_id: bootA, monotonic: 20, realtime: 10},
+			aSeqnumID: fileA,
+			b:         Entry{seqnum: 2, boot_id: bootA, monotonic: 10, realtime: 20},
+			bSeqnumID: fileB,
+			expected:  1,
+		},
+		{
+			name:      "realtime in different boots",
+			a:         Entry{seqnum: 1, boot_id: bootA, monotonic: 20, realtime: 10},
+			aSeqnumID: fileA,
+			b:         Entry{seqnum: 2, boot_id: bootB, monotonic: 10, realtime: 20},
+			bSeqnumID: fileB,
+			expected:  -1,
+		},
+		{
+			name:      "xor_hash for the same timestamps",
+			a:         Entry{seqnum: 1, boot_id: bootA, monotonic: 10, realtime: 10, xor_hash: 2},
+			aSeqnumID: fileA,
+			b:         Entry{seqnum: 1, boot_id: bootA, monotonic: 10, realtime: 10, xor_hash: 1},
+			bSeqnumID: fileB,
+			expected:  1,
+		},
+		{
+			name:      "the same entry",
+			a:         Entry{seqnum: 1, boot_id: bootA, monotonic: 10, realtime: 10, xor_hash: 1},
+			aSeqnumID: fileA,
+			b:         Entry{seqnum: 7, boot_id: bootA, monotonic: 10, realtime: 10, xor_hash: 1},
+			bSeqnumID: fileB,
+			expected:  0,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, compareEntries(&tt.a, tt.aSeqnumID, &tt.b, tt.bSeqnumID))
+			assert.Equal(t, -tt.expected, compareEntries(&tt.b, tt.bSeqnumID, &tt.a, tt.aSeqnumID))
+		})
+	}
+}
+
+// writeMessages writes entries with the given messages, realtime and monotonic are equal to the given timestamps
+func writeMessages(t *testing.T, writer *Writer, timestamps []uint64, messages []string) {
+	for i, message := range messages {
+		payloads := [][]byte{[]byte("MESSAGE=" + message), []byte("_BOOT_ID=0102030405060708090a0b0c0d0e0f10")}
+		require.NoError(t, writer.writeEntry(timestamps[i], timestamps[i], testBootID, payloads))
+	}
+}
+
+func TestMergedReader(t *testing.T) {
+	directory := t.TempDir()
+
+	writerA, err := NewWriter(filepath.Join(directory, "a.journal"), WriterConfig{Compact: true})
+	require.NoError(t, err)
+	defer writerA.Close()
+	writeMessages(t, writerA, []uint64{1, 3, 5}, []string{"a0", "a2", "duplicated"})
+
+	writerB, err := NewWriter(filepath.Join(directory, "b.journal"), WriterConfig{})
+	require.NoError(t, err)
+	writeMessages(t, writerB, []uint64{2, 5, 6}, []string{"b1", "duplicated", "b3"})
+	require.NoError(t, writerB.Close())
+
+	readerA, err := NewReader(filepath.Join(directory, "a.journal"))
+	require.NoError(t, err)
+	readerB, err := NewReader(filepath.Join(directory, "b.journal"))
+	require.NoError(t, err)
+
+	merged := NewMergedReader(readerA, readerB)
+	defer merged.Close()
+
+	assert.Equal(t, []string{"a0", "b1", "a2", "duplicated", "b3"}, readMessages(t, merged.Next))
+
+	// entries appended to the file after all of them have been read
+	writeMessages(t, writerA, []uint64{7, 8}, []string{"a4", "a5"})
+	assert.Equal(t, []string{"a4", "a5"}, readMessages(t, merged.Next))
+
+	_, err = merged.Next()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestMergedReaderFilter(t *testing.T) {
+	readerA, err := NewReader(writeTestJournal(t, WriterConfig{}, 20))
+	require.NoError(t, err)
+
+	merged := NewMergedReader(readerA)
+	defer merged.Close()
+	merged.SetFilter(&FilterChain{
+		Filters: []Filter{{Name: "_PID", Matches: []string{"3"}, Keep: true}},
+	})
+
+	// filter applies to files added later as well
+	readerB, err := NewReader(writeTestJournal(t, WriterConfig{Compact: true}, 20))
+	require.NoError(t, err)
+	merged.Add(readerB)
+
+	// both files contain the same entries
+	assert.Equal(t, testMessages(3, 10, 17), readMessages(t, merged.Next))
+}
+
+func TestMergedReaderReverse(t *testing.T) {
+	directory := t.TempDir()
+
+	writerA, err := NewWriter(filepath.Join(directory, "a.journal"), WriterConfig{Compact: true})
+	require.NoError(t, err)
+	writeMessages(t, writerA, []uint64{1, 3, 5}, []string{"a0", "a2", "duplicated"})
+	require.NoError(t, writerA.Close())
+
+	writerB, err := NewWriter(filepath.Join(directory, "b.journal"), WriterConfig{})
+	require.NoError(t, err)
+	writeMessages(t, writerB, []uint64{2, 5, 6}, []string{"b1", "duplicated", "b3"})
+	require.NoError(t, writerB.Close())
+
+	readerA, err := NewReader(filepath.Join(directory, "a.journal"))
+	require.NoError(t, err)
+	readerB, err := NewReader(filepath.Join(directory, "b.journal"))
+	require.NoError(t, err)
+
+	merged := NewMergedReader(readerA, readerB)
+	defer merged.Close()
+
+	assert.Equal(t, []string{"a0", "b1"}, []string{readMessage(t, merged), readMessage(t, merged)})
+
+	// waiting entries are returned to the files, so reading continues from the same position
+	require.NoError(t, merged.SetReverse(true))
+	assert.Equal(t, []string{"a0"}, readMessages(t, merged.Next))
+
+	require.NoError(t, merged.SetReverse(false))
+	require.NoError(t, readerA.SeekTail())
+	require.NoError(t, readerB.SeekTail())
+	require.NoError(t, merged.SetReverse(true))
+	assert.Equal(t, []string{"b3", "duplicated", "a2", "b1", "a0"}, readMessages(t, merged.Next))
+}
+
+// readMessage returns MESSAGE of the next entry of the merged reader
+func readMessage(t *testing.T, merged *MergedReader) string {
+	log, err := merged.Next()
+	require.NoError(t, err)
+	message, _ := log.Get("MESSAGE")
+	return message
+}
@@ -0,0 +1,199 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// errOutOfFile is returned if the range is not mapped even after the file has been mapped again
+// referenced objects can be missing in truncated files or in files which are being written
+var errOutOfFile = errors.New("out of the file")
+
+// maxMapSize is size of the biggest file which can be mapped, as length of the mapping is int
+// bigger files, e.g. over 2 GiB on 32-bit systems, are read using ReadAt
+var maxMapSize = int64(math.MaxInt)
+
+// mappedFile is read-only memory mapping of the journal file
+// journal files grow over time, so the mapping is extended on demand
+// file is read using ReadAt on systems without memory mapping
+// it is safe for concurrent use and can be shared by many readers
+type mappedFile struct {
+	mu sync.RWMutex
+
+	file *os.File
+	data []byte
+
+	// file has grown over maxMapSize, so it is not mapped anymore and it is read using ReadAt
+	tooLarge bool
+
+	// number of readers using the mapping
+	refs int
+}
+
+// newMappedFile maps the whole given file
+func newMappedFile(file *os.File) (*mappedFile, error) {
+	mf := mappedFile{
+		file: file,
+		data: []byte{},
+		refs: 1,
+	}
+
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	err := mf.remapLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	return &mf, nil
+}
+
+// remapLocked maps the file again if it has grown since the last mapping
+// write lock has to be held by the caller
+func (mf *mappedFile) remapLocked() error {
+	if !mmapSupported {
+		return nil
+	}
+
+	info, err := mf.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := info.Size()
+	if size <= int64(len(mf.data)) {
+		return nil
+	}
+
+	if size > maxMapSize {
+		mf.tooLarge = true
+		return mf.unmapLocked()
+	}
+
+	data, err := mapFile(mf.file, size)
+	if err != nil {
+		return fmt.Errorf("cannot map %s: %w", mf.file.Name(), err)
+	}
+
+	// nothing refers to the previous mapping, as read returns copies
+	err = mf.unmapLocked()
+	if err != nil {
+		return err
+	}
+	mf.data = data
+
+	return nil
+}
+
+// unmapLocked removes the current mapping if there is any
+// write lock has to be held by the caller
+func (mf *mappedFile) unmapLocked() error {
+	if len(mf.data) > 0 {
+		err := unmapFile(mf.data)
+		if err != nil {
+			return err
+		}
+	}
+	mf.data = []byte{}
+
+	return nil
+}
+
+// read returns copy of size bytes of the file starting with the given offset
+// it doesn't depend on any file position, so it can be called concurrently
+func (mf *mappedFile) read(offset uint64, size uint64) ([]byte, error) {
+	end := offset + size
+	if end < offset {
+		return nil, fmt.Errorf("invalid range (offset: %d, size: %d)", offset, size)
+	}
+
+	if !mmapSupported {
+		return mf.readAt(offset, size)
+	}
+
+	mf.mu.RLock()
+	if end <= uint64(len(mf.data)) {
+		defer mf.mu.RUnlock()
+		return append([]byte{}, mf.data[offset:end]...), nil
+	}
+	tooLarge := mf.tooLarge
+	mf.mu.RUnlock()
+
+	if tooLarge {
+		return mf.readAt(offset, size)
+	}
+
+	// data could be appended since the last mapping
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	err := mf.remapLocked()
+	if err != nil {
+		return nil, err
+	}
+	if mf.tooLarge {
+		return mf.readAt(offset, size)
+	}
+
+	if end > uint64(len(mf.data)) {
+		return nil, fmt.Errorf("range (offset: %d, size: %d) is %w (%d)", offset, size, errOutOfFile, len(mf.data))
+	}
+
+	return append([]byte{}, mf.data[offset:end]...), nil
+}
+
+// readAt returns size bytes of the file starting with the given offset using ReadAt
+// objects are updated in place, so the file is read on every call instead of keeping its copy
+func (mf *mappedFile) readAt(offset uint64, size uint64) ([]byte, error) {
+	info, err := mf.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// size is checked before allocation, as it can come from the corrupted object
+	if offset+size > uint64(info.Size()) {
+		return nil, fmt.Errorf("range (offset: %d, size: %d) is %w (%d)", offset, size, errOutOfFile, info.Size())
+	}
+
+	data := make([]byte, size)
+	read, err := mf.file.ReadAt(data, int64(offset))
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("range (offset: %d, size: %d) is %w (%d)", offset, size, errOutOfFile, int(offset)+read)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// acquire registers one more reader of the mapping
+func (mf *mappedFile) acquire() {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	mf.refs++
+}
+
+// close unmaps and closes the file once the last reader closes it
+func (mf *mappedFile) close() error {
+	mf.mu.Lock()
+	defer mf.mu.Unlock()
+
+	mf.refs--
+	if mf.refs > 0 {
+		return nil
+	}
+
+	err := mf.unmapLocked()
+	if err != nil {
+		return err
+	}
+
+	return mf.file.Close()
+}
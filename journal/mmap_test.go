@@ -0,0 +1,116 @@
+package journal
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// growingFile returns file with 100 bytes and function writing data at the offset
+func growingFile(t *testing.T) (*os.File, func(offset int64, data []byte)) {
+	path := filepath.Join(t.TempDir(), "test.journal")
+	require.NoError(t, os.WriteFile(path, bytes.Repeat([]byte{1}, 100), 0640))
+
+	writer, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	t.Cleanup(func() { writer.Close() })
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+
+	return file, func(offset int64, data []byte) {
+		_, err := writer.WriteAt(data, offset)
+		require.NoError(t, err)
+	}
+}
+
+func TestMappedFile(t *testing.T) {
+	file, write := growingFile(t)
+	mf, err := newMappedFile(file)
+	require.NoError(t, err)
+	defer mf.close()
+
+	testMappedFileRead(t, mf.read, write)
+	if mmapSupported {
+		assert.Len(t, mf.data, 200)
+	}
+
+	// copy is returned, so the mapping cannot be modified
+	data, err := mf.read(0, 4)
+	require.NoError(t, err)
+	data[0] = 0xff
+	data, err = mf.read(0, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 1, 1, 1}, data)
+}
+
+func TestMappedFileReadAt(t *testing.T) {
+	// the same is used on systems without memory mapping
+	file, write := growingFile(t)
+	mf, err := newMappedFile(file)
+	require.NoError(t, err)
+	defer mf.close()
+
+	testMappedFileRead(t, mf.readAt, write)
+}
+
+func TestMappedFileTooLarge(t *testing.T) {
+	// file grows over the limit after it has been mapped
+	maxMapSize = 150
+	t.Cleanup(func() { maxMapSize = int64(math.MaxInt) })
+
+	file, write := growingFile(t)
+	mf, err := newMappedFile(file)
+	require.NoError(t, err)
+	defer mf.close()
+
+	testMappedFileRead(t, mf.read, write)
+	if mmapSupported {
+		assert.True(t, mf.tooLarge)
+		assert.Empty(t, mf.data)
+	}
+}
+
+// testMappedFileRead checks reading of the file of growingFile
+func testMappedFileRead(t *testing.T, read func(offset uint64, size uint64) ([]byte, error), write func(offset int64, data []byte)) {
+	data, err := read(96, 4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 1, 1}, data)
+
+	// range past the end of the file
+	_, err = read(96, 8)
+	assert.ErrorIs(t, err, errOutOfFile)
+	_, err = read(0, 1<<40)
+	assert.ErrorIs(t, err, errOutOfFile)
+
+	// file is mapped again once it grows
+	write(100, bytes.Repeat([]byte{2}, 100))
+	data, err = read(96, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 1, 1, 1, 2, 2, 2, 2}, data)
+
+	// objects are updated in place
+	write(0, []byte{3})
+	data, err = read(0, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{3, 1}, data)
+
+	_, err = read(200, 1)
+	assert.ErrorIs(t, err, errOutOfFile)
+}
+
+func TestMappedFileInvalidRange(t *testing.T) {
+	file, _ := growingFile(t)
+	mf, err := newMappedFile(file)
+	require.NoError(t, err)
+	defer mf.close()
+
+	_, err = mf.read(math.MaxUint64, 2)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errOutOfFile)
+}
@@ -0,0 +1,216 @@
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Definitions of output formats
+const (
+	OUTPUT_KEY_VALUE   = "keyvalue"
+	OUTPUT_EXPORT      = "export"
+	OUTPUT_JSON        = "json"
+	OUTPUT_JSON_PRETTY = "json-pretty"
+	OUTPUT_JSON_SSE    = "json-sse"
+	OUTPUT_JSON_SEQ    = "json-seq"
+
+	// fields bigger than JSON_THRESHOLD are shown as null, unless all fields are requested
+	JSON_THRESHOLD = 4096
+)
+
+// LogWriter writes Logs in the output format
+type LogWriter interface {
+	Write(log Log) error
+}
+
+// keyValueWriter writes every attribute of the Log as key=value line
+// entries are separated by empty line
+type keyValueWriter struct {
+	writer *bufio.Writer
+}
+
+// Write writes the Log as key=value lines
+func (kw *keyValueWriter) Write(log Log) error {
+	kw.writer.WriteString("\n")
+	for _, field := range log.Fields() {
+		fmt.Fprintf(kw.writer, "%s=%s\n", field.Name, field.Value)
+	}
+
+	return kw.writer.Flush()
+}
+
+// jsonWriter writes Logs in the same way as journalctl -o json and its variants
+type jsonWriter struct {
+	writer *bufio.Writer
+	format string
+
+	// show fields bigger than JSON_THRESHOLD
+	showAll bool
+}
+
+// isJSONText returns true if the value can be encoded as JSON string
+// values which are not valid UTF-8 or contain control characters other than tab and new line are
+// encoded as array of bytes
+func isJSONText(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+
+	for _, r := range string(value) {
+		if (r < ' ' && r != '\t' && r != '\n') || (r >= 0x7f && r <= 0x9f) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// appendJSONString appends value encoded as JSON string
+// unlike json.Marshal it doesn't escape HTML characters
+func appendJSONString(buffer []byte, value string) []byte {
+	encoded := bytes.Buffer{}
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	// encoding string cannot fail
+	encoder.Encode(value)
+
+	return append(buffer, bytes.TrimSuffix(encoded.Bytes(), []byte("\n"))...)
+}
+
+// appendJSONValue appends value of the field as JSON string, array of bytes or null
+func (jw *jsonWriter) appendJSONValue(buffer []byte, field LogField, indent string) []byte {
+	switch {
+	case !jw.showAll && len(field.Name)+1+len(field.Value) >= JSON_THRESHOLD:
+		return append(buffer, "null"...)
+	case isJSONText(field.Value):
+		return appendJSONString(buffer, string(field.Value))
+	}
+
+	values := []string{}
+	for _, b := range field.Value {
+		values = append(values, strconv.Itoa(int(b)))
+	}
+	return appendJSONArray(buffer, values, indent)
+}
+
+// appendJSONArray appends already encoded values as JSON array
+// values are placed in separate lines if indent is not empty
+func appendJSONArray(buffer []byte, values []string, indent string) []byte {
+	buffer = append(buffer, '[')
+	for i, value := range values {
+		if i > 0 {
+			buffer = append(buffer, ',')
+		}
+		if indent != "" {
+			buffer = append(buffer, '\n')
+			buffer = append(buffer, indent+"\t"...)
+		}
+		buffer = append(buffer, value...)
+	}
+	if indent != "" {
+		buffer = append(buffer, '\n')
+		buffer = append(buffer, indent...)
+	}
+
+	return append(buffer, ']')
+}
+
+// encode returns the Log encoded as JSON object
+// repeated fields are encoded as array of values
+func (jw *jsonWriter) encode(log Log) []byte {
+	pretty := jw.format == OUTPUT_JSON_PRETTY
+	indent := ""
+	if pretty {
+		indent = "\t"
+	}
+
+	// group values of the same field preserving order of the fields
+	names := []string{}
+	values := map[string][]LogField{}
+	for _, field := range log.Fields() {
+		if _, ok := values[field.Name]; !ok {
+			names = append(names, field.Name)
+		}
+		values[field.Name] = append(values[field.Name], field)
+	}
+
+	buffer := []byte{'{'}
+	for i, name := range names {
+		if i > 0 {
+			buffer = append(buffer, ',')
+		}
+		if pretty {
+			buffer = append(buffer, '\n')
+			buffer = append(buffer, indent...)
+		}
+
+		buffer = appendJSONString(buffer, name)
+		if pretty {
+			buffer = append(buffer, " : "...)
+		} else {
+			buffer = append(buffer, ':')
+		}
+
+		fields := values[name]
+		if len(fields) == 1 {
+			buffer = jw.appendJSONValue(buffer, fields[0], indent)
+			continue
+		}
+
+		nested := ""
+		if pretty {
+			nested = indent + "\t"
+		}
+		encoded := []string{}
+		for _, field := range fields {
+			encoded = append(encoded, string(jw.appendJSONValue(nil, field, nested)))
+		}
+		buffer = appendJSONArray(buffer, encoded, indent)
+	}
+	if pretty {
+		buffer = append(buffer, '\n')
+	}
+
+	return append(buffer, '}')
+}
+
+// Write writes the Log as JSON object framed according to the format
+func (jw *jsonWriter) Write(log Log) error {
+	switch jw.format {
+	case OUTPUT_JSON_SSE:
+		jw.writer.WriteString("data: ")
+	case OUTPUT_JSON_SEQ:
+		// record separator
+		jw.writer.WriteByte(0x1e)
+	}
+
+	jw.writer.Write(jw.encode(log))
+
+	if jw.format == OUTPUT_JSON_SSE {
+		jw.writer.WriteString("\n\n")
+	} else {
+		jw.writer.WriteByte('\n')
+	}
+
+	return jw.writer.Flush()
+}
+
+// NewLogWriter returns LogWriter for the output format
+// showAll disables hiding of big fields in json formats
+func NewLogWriter(format string, showAll bool, w io.Writer) (LogWriter, error) {
+	switch format {
+	case OUTPUT_KEY_VALUE:
+		return &keyValueWriter{writer: bufio.NewWriter(w)}, nil
+	case OUTPUT_EXPORT:
+		return NewExportWriter(w), nil
+	case OUTPUT_JSON, OUTPUT_JSON_PRETTY, OUTPUT_JSON_SSE, OUTPUT_JSON_SEQ:
+		return &jsonWriter{writer: bufio.NewWriter(w), format: format, showAll: showAll}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
@@ -0,0 +1,153 @@
+package journal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsJSONText(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{
+			name:     "text",
+			value:    "Started session-12.scope - Session 12 of User root.",
+			expected: true,
+		},
+		{
+			name:     "new line and tab",
+			value:    "first\n\tsecond",
+			expected: true,
+		},
+		{
+			name:     "utf-8",
+			value:    "zażółć gęślą jaźń",
+			expected: true,
+		},
+		{
+			name:     "null byte",
+			value:    "a\x00b",
+			expected: false,
+		},
+		{
+			name:     "escape",
+			value:    "\x1b[0;1;31mred",
+			expected: false,
+		},
+		{
+			name:     "invalid utf-8",
+			value:    "\xff",
+			expected: false,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, isJSONText([]byte(tt.value)))
+		})
+	}
+}
+
+func TestLogWriter(t *testing.T) {
+	log := testLog(
+		ATTRIBUTE_CURSOR, "s=1;i=1",
+		ATTRIBUTE_REALTIME_TIMESTAMP, "1700000000000000",
+		"BIN", "\x00\x01",
+		"MESSAGE", "<b>\"quoted\"</b>\nnext",
+	)
+
+	testCases := []struct {
+		format   string
+		showAll  bool
+		expected string
+	}{
+		{
+			format:   OUTPUT_KEY_VALUE,
+			expected: "\n__CURSOR=s=1;i=1\n__REALTIME_TIMESTAMP=1700000000000000\nBIN=\x00\x01\nMESSAGE=<b>\"quoted\"</b>\nnext\n",
+		},
+		{
+			format:   OUTPUT_JSON,
+			expected: `{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1700000000000000","BIN":[0,1],"MESSAGE":"<b>\"quoted\"</b>\nnext"}` + "\n",
+		},
+		{
+			format: OUTPUT_JSON_PRETTY,
+			expected: "{\n" +
+				"\t\"__CURSOR\" : \"s=1;i=1\",\n" +
+				"\t\"__REALTIME_TIMESTAMP\" : \"1700000000000000\",\n" +
+				"\t\"BIN\" : [\n\t\t0,\n\t\t1\n\t],\n" +
+				"\t\"MESSAGE\" : \"<b>\\\"quoted\\\"</b>\\nnext\"\n" +
+				"}\n",
+		},
+		{
+			format:   OUTPUT_JSON_SSE,
+			expected: `data: {"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1700000000000000","BIN":[0,1],"MESSAGE":"<b>\"quoted\"</b>\nnext"}` + "\n\n",
+		},
+		{
+			format:   OUTPUT_JSON_SEQ,
+			expected: "\x1e" + `{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1700000000000000","BIN":[0,1],"MESSAGE":"<b>\"quoted\"</b>\nnext"}` + "\n",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.format, func(t *testing.T) {
+			buffer := bytes.Buffer{}
+			writer, err := NewLogWriter(tt.format, tt.showAll, &buffer)
+			require.NoError(t, err)
+			require.NoError(t, writer.Write(log))
+			assert.Equal(t, tt.expected, buffer.String())
+		})
+	}
+}
+
+func TestJSONWriterBigField(t *testing.T) {
+	big := strings.Repeat("x", JSON_THRESHOLD-4)
+	small := strings.Repeat("x", JSON_THRESHOLD-7)
+	// field name, equal sign and value are exactly JSON_THRESHOLD bytes
+	log := testLog("BIG", big, "SMALL", small)
+
+	buffer := bytes.Buffer{}
+	writer, err := NewLogWriter(OUTPUT_JSON, false, &buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(log))
+	assert.Equal(t, `{"BIG":null,"SMALL":"`+small+`"}`+"\n", buffer.String())
+
+	buffer.Reset()
+	writer, err = NewLogWriter(OUTPUT_JSON, true, &buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(log))
+	assert.Equal(t, `{"BIG":"`+big+`","SMALL":"`+small+`"}`+"\n", buffer.String())
+}
+
+func TestJSONWriterRepeatedFields(t *testing.T) {
+	log := testLog(
+		"TAG", "a",
+		"MESSAGE", "text",
+		"TAG", "\x00",
+		"TAG", "b",
+	)
+
+	buffer := bytes.Buffer{}
+	writer, err := NewLogWriter(OUTPUT_JSON, false, &buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(log))
+	assert.Equal(t, `{"TAG":["a",[0],"b"],"MESSAGE":"text"}`+"\n", buffer.String())
+
+	buffer.Reset()
+	writer, err = NewLogWriter(OUTPUT_JSON_PRETTY, false, &buffer)
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(log))
+	expected := "{\n" +
+		"\t\"TAG\" : [\n\t\t\"a\",\n\t\t[\n\t\t\t0\n\t\t],\n\t\t\"b\"\n\t],\n" +
+		"\t\"MESSAGE\" : \"text\"\n" +
+		"}\n"
+	assert.Equal(t, expected, buffer.String())
+}
+
+func TestNewLogWriterUnknownFormat(t *testing.T) {
+	_, err := NewLogWriter("short-iso", false, &bytes.Buffer{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package journal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// NewPriorityFilter returns filter keeping entries with PRIORITY in the range, the same as journalctl -p
+// single level, e.g. "warning" or "4", keeps the level and all the more severe ones
+// range "FROM..TO", e.g. "err..warning", keeps the levels between both of them
+// levels are given by names or numbers and entries without PRIORITY are not kept
+func NewPriorityFilter(priorities string) (Filter, error) {
+	from, to, isRange := strings.Cut(priorities, "..")
+	if !isRange {
+		from, to = PRIORITY_EMERGENCY, priorities
+	}
+
+	fromID, err := priorityID(from)
+	if err != nil {
+		return Filter{}, err
+	}
+	toID, err := priorityID(to)
+	if err != nil {
+		return Filter{}, err
+	}
+	if fromID > toID {
+		fromID, toID = toID, fromID
+	}
+
+	// exact matches are looked up in the index unlike the numeric comparison
+	matches := []string{}
+	for id := fromID; id <= toID; id++ {
+		matches = append(matches, strconv.Itoa(id))
+	}
+
+	return Filter{Name: "PRIORITY", Matches: matches, Keep: true}, nil
+}
+
+// NewFacilityFilter returns filter keeping entries with SYSLOG_FACILITY equal to any of the facilities,
+// the same as journalctl --facility
+// facilities are given by names, e.g. "auth", or numbers
+func NewFacilityFilter(facilities ...string) (Filter, error) {
+	matches := []string{}
+	for _, facility := range facilities {
+		id, err := facilityID(facility)
+		if err != nil {
+			return Filter{}, err
+		}
+		matches = append(matches, strconv.Itoa(id))
+	}
+
+	return Filter{Name: "SYSLOG_FACILITY", Matches: matches, Keep: true}, nil
+}
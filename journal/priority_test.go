@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPriorityFilter(t *testing.T) {
+	testCases := []struct {
+		name          string
+		priorities    string
+		expected      []string
+		expectedError bool
+	}{
+		{
+			name:       "single level",
+			priorities: "warning",
+			expected:   []string{"0", "1", "2", "3", "4"},
+		},
+		{
+			name:       "single number",
+			priorities: "0",
+			expected:   []string{"0"},
+		},
+		{
+			name:       "range",
+			priorities: "err..warning",
+			expected:   []string{"3", "4"},
+		},
+		{
+			name:       "reversed range of names and numbers",
+			priorities: "debug..5",
+			expected:   []string{"5", "6", "7"},
+		},
+		{
+			name:          "unknown name",
+			priorities:    "error",
+			expectedError: true,
+		},
+		{
+			name:          "number out of range",
+			priorities:    "3..8",
+			expectedError: true,
+		},
+		{
+			name:          "open range",
+			priorities:    "..warning",
+			expectedError: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := NewPriorityFilter(tt.priorities)
+			if tt.expectedError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, Filter{Name: "PRIORITY", Matches: tt.expected, Keep: true}, filter)
+		})
+	}
+}
+
+func TestNewFacilityFilter(t *testing.T) {
+	filter, err := NewFacilityFilter("auth", "10", "local7")
+	require.NoError(t, err)
+	assert.Equal(t, Filter{Name: "SYSLOG_FACILITY", Matches: []string{"4", "10", "23"}, Keep: true}, filter)
+
+	// priority and facility compose in the filter chain
+	chain := FilterChain{Filters: []Filter{filter}}
+	priority, err := NewPriorityFilter("err")
+	require.NoError(t, err)
+	chain.Filters = append(chain.Filters, priority)
+
+	assert.True(t, chain.filterIn(testLog("PRIORITY", "2", "SYSLOG_FACILITY", "4")))
+	assert.False(t, chain.filterIn(testLog("PRIORITY", "6", "SYSLOG_FACILITY", "4")))
+	assert.False(t, chain.filterIn(testLog("PRIORITY", "2", "SYSLOG_FACILITY", "3")))
+	assert.False(t, chain.filterIn(testLog("SYSLOG_FACILITY", "4")))
+
+	for _, facility := range []string{"", "local8", "24", "-1"} {
+		_, err = NewFacilityFilter(facility)
+		assert.Error(t, err, facility)
+	}
+}
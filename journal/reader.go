@@ -0,0 +1,1078 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// Reader object
+// it is safe for concurrent use, but all users share the same position
+// use fork to get independent position for the same file
+type Reader struct {
+	file *os.File
+
+	// objects are read from the memory mapping of the file
+	mapping *mappedFile
+
+	// header is replaced as a whole on every reload
+	header atomic.Pointer[Header]
+
+	// mu guards the position and entryArrays
+	mu sync.Mutex
+
+	nextArrayOffset uint64
+	nextItemOffset  int
+
+	// offsets of entry arrays in the main chain, in order
+	// entry arrays are singly linked, so it is required to move backward
+	entryArrays []uint64
+
+	// MaxDataSize is maximum size of decompressed Data payload, bigger payloads fail to read
+	// it has to be set before the reader is used
+	MaxDataSize int
+
+	// entries not matching the filter are skipped by Next and Previous
+	filter atomic.Pointer[FilterChain]
+
+	// entries matching the filter found using data hash table, guarded by mu
+	index filterIndex
+}
+
+// filterIndex keeps offsets of the entries matching the filter found using data hash table
+type filterIndex struct {
+	filter *FilterChain
+
+	// sorted offsets of the matching entries, all is true if the filter doesn't narrow the entries
+	offsets []uint64
+	all     bool
+
+	// entries stored after tail object are not indexed and they are read one by one
+	tailObjectOffset uint64
+	nEntries         uint64
+}
+
+func newReaderFromPointer(file *os.File) (*Reader, error) {
+	mapping, err := newMappedFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := Reader{
+		file:           file,
+		mapping:        mapping,
+		nextItemOffset: 0,
+		MaxDataSize:    DEFAULT_MAX_DATA_SIZE,
+	}
+
+	err = reader.loadHeader()
+	if err != nil {
+		mapping.close()
+		return nil, err
+	}
+
+	// reader is not shared yet, so there is no need to lock it
+	reader.resetOffsetLocked()
+	err = reader.updateEntryArraysLocked()
+	if err != nil {
+		mapping.close()
+		return nil, err
+	}
+
+	// return Reader
+	return &reader, nil
+}
+
+// NewReader creates Reader for the given filename
+// position is set right before the first entry
+func NewReader(filename string) (*Reader, error) {
+	// Open file
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReaderFromPointer(file)
+}
+
+// fork returns new Reader for the same file with its own copy of the position
+// file and its mapping are shared, so both readers can be used concurrently
+func (r *Reader) fork() *Reader {
+	r.mapping.acquire()
+
+	reader := Reader{
+		file:        r.file,
+		mapping:     r.mapping,
+		MaxDataSize: r.MaxDataSize,
+	}
+	reader.header.Store(r.getHeader())
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reader.nextArrayOffset = r.nextArrayOffset
+	reader.nextItemOffset = r.nextItemOffset
+	reader.entryArrays = slices.Clone(r.entryArrays)
+
+	return &reader
+}
+
+// Close unmaps and closes the journal file
+// file is closed once all forks of the reader are closed
+func (r *Reader) Close() error {
+	return r.mapping.close()
+}
+
+// Name returns name of the journal file
+func (r *Reader) Name() string {
+	return r.file.Name()
+}
+
+// getHeader returns the most recently loaded header
+func (r *Reader) getHeader() *Header {
+	return r.header.Load()
+}
+
+// isDeleted returns true if the file has been removed from the file system
+func (r *Reader) isDeleted() (bool, error) {
+	info, err := r.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	return isRemoved(info), nil
+}
+
+// isCompact returns true if the file uses compact format
+func (r *Reader) isCompact() bool {
+	return r.getHeader().isCompact()
+}
+
+func (r *Reader) loadHeader() error {
+	// prepare buffer and read file header
+	// ReadAt doesn't depend on file position, so it is safe for concurrent use
+	buffer := make([]byte, HEADER_MAX_SIZE)
+	read, err := r.file.ReadAt(buffer, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	header, err := newHeader(buffer[:read])
+
+	if err != nil {
+		return err
+	}
+
+	// check file signature
+	if string(header.signature[:]) != "LPKSHHRH" {
+		return errors.New("file signature is invalid")
+	}
+
+	if header.incompatible_flags&^HEADER_INCOMPATIBLE_SUPPORTED != 0 {
+		return fmt.Errorf("file has unknown incompatible flags (%d): %w", header.incompatible_flags, errors.ErrUnsupported)
+	}
+
+	r.header.Store(header)
+
+	return nil
+}
+
+// SeekHead sets position right before the first entry
+func (r *Reader) SeekHead() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.resetOffsetLocked()
+}
+
+// resetOffsetLocked sets position right before the first entry
+// lock has to be held by the caller
+func (r *Reader) resetOffsetLocked() {
+	r.nextArrayOffset = r.getHeader().entry_array_offset
+	r.nextItemOffset = 0
+}
+
+// SeekTail sets position right after the last entry
+func (r *Reader) SeekTail() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.seekTailLocked()
+}
+
+// seekTailLocked sets position right after the last entry
+// it uses tail entry array stored in the header (added in 252) if available
+// and walks the chain of entry arrays otherwise
+// lock has to be held by the caller
+func (r *Reader) seekTailLocked() error {
+	ok, err := r.seekTailFromHeaderLocked()
+	if err != nil || ok {
+		return err
+	}
+
+	err = r.updateEntryArraysLocked()
+	if err != nil {
+		return err
+	}
+
+	if len(r.entryArrays) == 0 {
+		r.resetOffsetLocked()
+		return nil
+	}
+
+	lastArrayOffset := r.entryArrays[len(r.entryArrays)-1]
+	entryArray, err := r.getEntryArray(lastArrayOffset)
+	if err != nil {
+		return err
+	}
+
+	r.nextArrayOffset = lastArrayOffset
+	r.nextItemOffset = len(arrayItems(entryArray))
+	return nil
+}
+
+// seekTailFromHeaderLocked sets position right after the last entry using tail fields of the header
+// it returns false if the header doesn't contain them or they are not consistent
+// lock has to be held by the caller
+func (r *Reader) seekTailFromHeaderLocked() (bool, error) {
+	header := r.getHeader()
+	if header.header_size <= 256 || header.tail_entry_array_offset == 0 || header.tail_entry_array_n_entries == 0 {
+		return false, nil
+	}
+
+	arrayOffset := uint64(header.tail_entry_array_offset)
+	entryArray, err := r.getEntryArray(arrayOffset)
+	if err != nil {
+		return false, err
+	}
+
+	nEntries := int(header.tail_entry_array_n_entries)
+	if entryArray.objectType != OBJECT_ENTRY_ARRAY || nEntries > entryArray.countItems {
+		return false, nil
+	}
+
+	// tail entry offset (added in 254) has to be the last item of the array
+	if header.header_size > 264 && header.tail_entry_offset != 0 && entryArray.items()[nEntries-1] != header.tail_entry_offset {
+		return false, nil
+	}
+
+	r.nextArrayOffset = arrayOffset
+	r.nextItemOffset = nEntries
+	return true, nil
+}
+
+// seekTailEntries sets position right before the last n entries
+func (r *Reader) seekTailEntries(n int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.seekTailLocked()
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		entry, err := r.getPreviousEntryLocked()
+		if err != nil {
+			return err
+		}
+
+		// there is less than n entries in the file
+		if entry == nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// updateEntryArraysLocked appends entry arrays added to the main chain since the last update
+// lock has to be held by the caller
+func (r *Reader) updateEntryArraysLocked() error {
+	arrayOffset := r.getHeader().entry_array_offset
+
+	// continue from the last known entry array
+	if len(r.entryArrays) > 0 {
+		entryArray, err := r.getEntryArray(r.entryArrays[len(r.entryArrays)-1])
+		if err != nil {
+			return err
+		}
+		arrayOffset = entryArray.next_entry_array_offset
+	}
+
+	for arrayOffset != 0 {
+		// entry arrays are appended to the file, so offsets in the chain are growing
+		if len(r.entryArrays) > 0 && arrayOffset <= r.entryArrays[len(r.entryArrays)-1] {
+			return fmt.Errorf("entry array chain is not growing at %d", arrayOffset)
+		}
+
+		r.entryArrays = append(r.entryArrays, arrayOffset)
+
+		entryArray, err := r.getEntryArray(arrayOffset)
+		if err != nil {
+			return err
+		}
+		arrayOffset = entryArray.next_entry_array_offset
+	}
+
+	return nil
+}
+
+// entryArrayIndexLocked returns position of the entry array in the main chain
+// lock has to be held by the caller
+func (r *Reader) entryArrayIndexLocked(arrayOffset uint64) (int, error) {
+	index := slices.Index(r.entryArrays, arrayOffset)
+	if index >= 0 {
+		return index, nil
+	}
+
+	// entry array could be added after the last update
+	err := r.updateEntryArraysLocked()
+	if err != nil {
+		return 0, err
+	}
+
+	index = slices.Index(r.entryArrays, arrayOffset)
+	if index < 0 {
+		return 0, fmt.Errorf("entry array %d is not part of the main chain", arrayOffset)
+	}
+	return index, nil
+}
+
+// getObject reads the object starting with the given offset
+// returned object owns its payload, so it stays valid after next reads
+func (r *Reader) getObject(offset uint64) (*ObjectHeader, error) {
+	// read ObjectHeader
+	headerData, err := r.mapping.read(offset, OBJECT_HEADER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	oh, err := newObjectHeader(headerData)
+	if err != nil {
+		return nil, err
+	}
+	if oh.size < OBJECT_HEADER_SIZE {
+		return nil, fmt.Errorf("object at %d is smaller (%d) than object header", offset, oh.size)
+	}
+
+	// load payload to the ObjectHeader
+	payload, err := r.mapping.read(offset+OBJECT_HEADER_SIZE, oh.size-OBJECT_HEADER_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	oh.setPayload(payload)
+
+	// return ObjectHeader
+	return oh, nil
+}
+
+// getData returns Data object starting with given offset
+func (r *Reader) getData(offset uint64) (*Data, error) {
+	// read object starting with given offset
+	oh, err := r.getObject(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// return Data object
+	return oh.Data(r.isCompact())
+}
+
+// getEntryArray returns EntryArray object starting with given offset
+func (r *Reader) getEntryArray(offset uint64) (*EntryArray, error) {
+	// read object starting with given offset
+	oh, err := r.getObject(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// return EntryArray object
+	return oh.EntryArray(r.isCompact())
+}
+
+// getEntry returns Entry object starting with given offset
+func (r *Reader) getEntry(offset uint64) (*Entry, error) {
+	oh, err := r.getObject(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// return EntryArray object
+	return oh.Entry(r.isCompact())
+}
+
+// getField returns Field object starting with given offset
+func (r *Reader) getField(offset uint64) (*Field, error) {
+	oh, err := r.getObject(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if oh.objectType != OBJECT_FIELD {
+		return nil, fmt.Errorf("object at %d is not a field object (%d)", offset, oh.objectType)
+	}
+
+	// return Field object
+	return oh.Field()
+}
+
+// getFieldHashTable returns field HashTable object of the file
+func (r *Reader) getFieldHashTable() (*HashTable, error) {
+	// header points to the items, not to the object itself
+	offset := r.getHeader().field_hash_table_offset - OBJECT_HEADER_SIZE
+	oh, err := r.getObject(offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if oh.objectType != OBJECT_FIELD_HASH_TABLE {
+		return nil, fmt.Errorf("object at %d is not a field hash table object (%d)", offset, oh.objectType)
+	}
+
+	// return HashTable object
+	return oh.FieldHashTable()
+}
+
+// getHashItem reads HashItem stored at the given offset
+func (r *Reader) getHashItem(offset uint64) (*HashItem, error) {
+	buffer, err := r.mapping.read(offset, HASH_ITEM_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HashItem{
+		head_hash_offset: le64(([8]byte)(buffer[0:8])),
+		tail_hash_offset: le64(([8]byte)(buffer[8:16])),
+	}, nil
+}
+
+// findData returns Data object for the given payload (FIELD=value) using data hash table
+// it returns nil if there is no such Data object in the file
+func (r *Reader) findData(payload []byte) (*Data, error) {
+	header := r.getHeader()
+	if header.data_hash_table_size < HASH_ITEM_SIZE {
+		return nil, nil
+	}
+
+	hash := header.hash(payload)
+
+	// find the bucket for the hash and follow the chain of Data objects
+	buckets := header.data_hash_table_size / HASH_ITEM_SIZE
+	hashItem, err := r.getHashItem(header.data_hash_table_offset + (hash%buckets)*HASH_ITEM_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := uint64(0)
+	for offset := hashItem.head_hash_offset; offset != 0; {
+		// chain cannot be longer than number of Data objects in the file
+		if depth > header.n_data && header.n_data > 0 {
+			return nil, fmt.Errorf("data hash chain for hash %x is too long", hash)
+		}
+		depth++
+
+		data, err := r.getData(offset)
+		if err != nil {
+			return nil, err
+		}
+
+		if data.objectType != OBJECT_DATA {
+			return nil, fmt.Errorf("object at %d is not a data object (%d)", offset, data.objectType)
+		}
+
+		if data.hash == hash {
+			dataPayload, err := data.getPayload(r.MaxDataSize)
+			if err != nil {
+				return nil, err
+			}
+
+			if bytes.Equal(dataPayload, payload) {
+				return data, nil
+			}
+		}
+
+		offset = data.next_hash_offset
+	}
+
+	return nil, nil
+}
+
+// findField returns Field object for the given field name using field hash table
+// it returns nil if there is no such Field object in the file
+func (r *Reader) findField(name []byte) (*Field, error) {
+	header := r.getHeader()
+	if header.field_hash_table_size < HASH_ITEM_SIZE {
+		return nil, nil
+	}
+
+	hash := header.hash(name)
+
+	// find the bucket for the hash and follow the chain of Field objects
+	buckets := header.field_hash_table_size / HASH_ITEM_SIZE
+	hashItem, err := r.getHashItem(header.field_hash_table_offset + (hash%buckets)*HASH_ITEM_SIZE)
+	if err != nil {
+		return nil, err
+	}
+
+	depth := uint64(0)
+	for offset := hashItem.head_hash_offset; offset != 0; {
+		// chain cannot be longer than number of Field objects in the file
+		if depth > header.n_fields && header.n_fields > 0 {
+			return nil, fmt.Errorf("field hash chain for hash %x is too long", hash)
+		}
+		depth++
+
+		field, err := r.getField(offset)
+		if err != nil {
+			return nil, err
+		}
+
+		if field.hash == hash && bytes.Equal(field.payload, name) {
+			return field, nil
+		}
+
+		offset = field.next_hash_offset
+	}
+
+	return nil, nil
+}
+
+// Fields returns names of all fields stored in the file
+// it walks the field hash table, so no entry is visited
+func (r *Reader) Fields() ([]string, error) {
+	fields := []string{}
+
+	header := r.getHeader()
+	if header.field_hash_table_size < HASH_ITEM_SIZE {
+		return fields, nil
+	}
+
+	hashTable, err := r.getFieldHashTable()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hashItem := range hashTable.items {
+		depth := uint64(0)
+		for offset := hashItem.head_hash_offset; offset != 0; {
+			// chain cannot be longer than number of Field objects in the file
+			if depth > header.n_fields && header.n_fields > 0 {
+				return nil, fmt.Errorf("field hash chain starting at %d is too long", hashItem.head_hash_offset)
+			}
+			depth++
+
+			field, err := r.getField(offset)
+			if err != nil {
+				return nil, err
+			}
+
+			fields = append(fields, string(field.payload))
+			offset = field.next_hash_offset
+		}
+	}
+
+	slices.Sort(fields)
+	return fields, nil
+}
+
+// FieldValues returns all distinct values of the given field stored in the file
+// it follows the chain of Data objects of the field, so no entry is visited
+func (r *Reader) FieldValues(name string) ([]string, error) {
+	values := []string{}
+
+	err := r.walkFieldData(name, func(data *Data, value string) error {
+		values = append(values, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(values)
+	return slices.Compact(values), nil
+}
+
+// walkFieldData calls fn for every Data object of the given field along with its value
+// it follows the chain of Data objects of the field and stops on the first error
+func (r *Reader) walkFieldData(name string, fn func(data *Data, value string) error) error {
+	field, err := r.findField([]byte(name))
+	if err != nil {
+		return err
+	}
+
+	// there is no such field in the file
+	if field == nil {
+		return nil
+	}
+
+	header := r.getHeader()
+	depth := uint64(0)
+	for offset := field.head_data_offset; offset != 0; {
+		// chain cannot be longer than number of Data objects in the file
+		if depth > header.n_data && header.n_data > 0 {
+			return fmt.Errorf("field %s data chain is too long", name)
+		}
+		depth++
+
+		data, err := r.getData(offset)
+		if err != nil {
+			return err
+		}
+
+		if data.objectType != OBJECT_DATA {
+			return fmt.Errorf("object at %d is not a data object (%d)", offset, data.objectType)
+		}
+
+		_, value, err := data.getPayloadKeyValue(r.MaxDataSize)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(data, value); err != nil {
+			return err
+		}
+		offset = data.next_field_offset
+	}
+
+	return nil
+}
+
+// getDataEntries returns offsets of all entries which reference the given Data object
+func (r *Reader) getDataEntries(data *Data) ([]uint64, error) {
+	entries := []uint64{}
+
+	if data.n_entries == 0 {
+		return entries, nil
+	}
+
+	// first entry is stored inline
+	entries = append(entries, data.entry_offset)
+
+	// rest of them are stored in the chain of entry arrays
+	nEntries := data.n_entries
+	for arrayOffset := data.entry_array_offset; arrayOffset != 0 && uint64(len(entries)) < nEntries; {
+		entryArray, err := r.getEntryArray(arrayOffset)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range entryArray.items() {
+			if item == 0 || uint64(len(entries)) == nEntries {
+				break
+			}
+			entries = append(entries, item)
+		}
+
+		arrayOffset = entryArray.next_entry_array_offset
+	}
+
+	return entries, nil
+}
+
+// getXorHash computes xor_hash of the entry based on the payloads of its Data objects
+// it always uses jenkins lookup3, also for files with keyed hash
+func (r *Reader) getXorHash(entry *Entry) (uint64, error) {
+	xorHash := uint64(0)
+
+	for _, item := range entry.items() {
+		if item.object_offset == 0 {
+			break
+		}
+
+		data, err := r.getData(item.object_offset)
+		if err != nil {
+			return 0, err
+		}
+
+		payload, err := data.getPayload(r.MaxDataSize)
+		if err != nil {
+			return 0, err
+		}
+
+		xorHash ^= jenkinsHash64(payload)
+	}
+
+	return xorHash, nil
+}
+
+// checkXorHash returns error if xor_hash of the entry doesn't match its Data objects
+func (r *Reader) checkXorHash(entry *Entry) error {
+	xorHash, err := r.getXorHash(entry)
+	if err != nil {
+		return err
+	}
+
+	if xorHash != entry.xor_hash {
+		return fmt.Errorf("invalid xor_hash for entry %d: expected %x, got %x", entry.seqnum, entry.xor_hash, xorHash)
+	}
+
+	return nil
+}
+
+// initAttributes returns Log containing attributes based on the entry structure
+func (r *Reader) initAttributes(entry *Entry) Log {
+	log := Log{}
+	log.AddString(ATTRIBUTE_CURSOR, r.getCursor(entry).String())
+	log.AddString(ATTRIBUTE_REALTIME_TIMESTAMP, fmt.Sprintf("%d", entry.realtime))
+	log.AddString(ATTRIBUTE_MONOTONIC_TIMESTAMP, fmt.Sprintf("%d", entry.monotonic))
+	return log
+}
+
+// readData from specific Entry
+// fields are returned in the order of the entry items, repeated fields are kept
+func (r *Reader) readData(entry *Entry) (Log, error) {
+	// get list of Data offset
+	dataOffsets := entry.items()
+	log := r.initAttributes(entry)
+
+	for _, dataOffset := range dataOffsets {
+		// there is nothing more to read for this Data
+		if dataOffset.object_offset == 0 {
+			break
+		}
+
+		// read Data starting with given offset
+		dataObject, err := r.getData(dataOffset.object_offset)
+		if err != nil {
+			return Log{}, r.readError(dataOffset.object_offset, err)
+		}
+
+		// get key value pair of the Data and append to the attributes list
+		key, value, err := dataObject.getPayloadKeyValue(r.MaxDataSize)
+		if err != nil {
+			return Log{}, r.readError(dataOffset.object_offset, err)
+		}
+		log.AddString(key, value)
+	}
+
+	return log, nil
+}
+
+// SetFilter limits entries returned by Next and Previous to the ones matching the filter chain
+// nil filter chain disables filtering
+func (r *Reader) SetFilter(filterChain *FilterChain) {
+	r.filter.Store(filterChain)
+}
+
+// Next moves position forward and returns the next entry matching the filter
+// it returns io.EOF if there are no more entries
+// it returns ReadError if the entry cannot be read, position stays right before the entry in such case
+func (r *Reader) Next() (Log, error) {
+	_, log, err := r.nextMatchingEntry(false)
+	return log, err
+}
+
+// Previous moves position backward and returns the previous entry matching the filter
+// it returns io.EOF if the beginning of the file has been reached
+// it returns ReadError if the entry cannot be read, position stays right after the entry in such case
+func (r *Reader) Previous() (Log, error) {
+	_, log, err := r.nextMatchingEntry(true)
+	return log, err
+}
+
+// nextMatchingEntry reads entries forward or backward until one of them matches the filter
+// entries not referenced by the matching Data objects are skipped without reading them
+// it returns the entry along with its Log
+// position is restored if the entry cannot be read, so it can be read again or skipped
+func (r *Reader) nextMatchingEntry(backward bool) (*Entry, Log, error) {
+	filterChain := r.filter.Load()
+
+	next := r.getNextEntryLocked
+	if backward {
+		next = r.getPreviousEntryLocked
+	}
+
+	// position is saved and restored in the same locked section,
+	// so concurrent users of the reader cannot be moved back
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		arrayOffset, itemOffset := r.nextArrayOffset, r.nextItemOffset
+
+		if filterChain != nil {
+			found, err := r.seekMatchingLocked(filterChain, backward)
+			if err != nil {
+				r.nextArrayOffset, r.nextItemOffset = arrayOffset, itemOffset
+				return nil, Log{}, err
+			}
+			if !found {
+				return nil, Log{}, io.EOF
+			}
+		}
+
+		entry, err := next()
+		if err != nil {
+			return nil, Log{}, err
+		}
+		if entry == nil {
+			return nil, Log{}, io.EOF
+		}
+
+		log, err := r.readData(entry)
+		if err != nil {
+			r.nextArrayOffset, r.nextItemOffset = arrayOffset, itemOffset
+			return nil, Log{}, err
+		}
+
+		// index narrows the entries, but doesn't have to be exact
+		if filterChain == nil || filterChain.filterIn(log) {
+			return entry, log, nil
+		}
+	}
+}
+
+// seekMatchingLocked moves position right before the next matching entry found in the index
+// entries which are not indexed yet are not skipped
+// it returns false if there is no matching entry in that direction, the position is moved to the end
+// lock has to be held by the caller
+func (r *Reader) seekMatchingLocked(filterChain *FilterChain, backward bool) (bool, error) {
+	// offset of the entry which is going to be read
+	arrayOffset, itemOffset := r.nextArrayOffset, r.nextItemOffset
+	var current uint64
+	var err error
+	if backward {
+		current, err = r.previousEntryOffsetLocked()
+	} else {
+		current, err = r.nextEntryOffsetLocked()
+	}
+	r.nextArrayOffset, r.nextItemOffset = arrayOffset, itemOffset
+	// nothing to read or the error is reported by reading the entry
+	if err != nil || current == 0 {
+		return true, nil
+	}
+
+	index, err := r.filterIndexLocked(filterChain, current)
+	if err != nil {
+		return false, err
+	}
+	if index.all || current > index.tailObjectOffset {
+		return true, nil
+	}
+
+	i, found := slices.BinarySearch(index.offsets, current)
+	if found {
+		return true, nil
+	}
+
+	if backward {
+		if i == 0 {
+			return false, r.seekOffsetLocked(0)
+		}
+		// position right after the matching entry
+		return true, r.seekOffsetLocked(index.offsets[i-1] + 1)
+	}
+
+	// entries which are not indexed are read one by one
+	if i == len(index.offsets) {
+		return true, r.seekOffsetLocked(index.tailObjectOffset + 1)
+	}
+	return true, r.seekOffsetLocked(index.offsets[i])
+}
+
+// filterIndexLocked returns index of the filter for reading the entry at the given offset
+// index is built again if the filter changes or the entry is not indexed and the number of entries
+// doubled since the last build, so following the growing file doesn't rebuild it for every entry
+// lock has to be held by the caller
+func (r *Reader) filterIndexLocked(filterChain *FilterChain, current uint64) (*filterIndex, error) {
+	header := r.getHeader()
+	index := &r.index
+
+	if index.filter == filterChain && (current <= index.tailObjectOffset || header.n_entries <= 2*index.nEntries) {
+		return index, nil
+	}
+
+	offsets, all, err := filterChain.entries(r)
+	if err != nil {
+		return nil, err
+	}
+
+	*index = filterIndex{
+		filter:           filterChain,
+		offsets:          offsets,
+		all:              all,
+		tailObjectOffset: header.tail_object_offset,
+		nEntries:         header.n_entries,
+	}
+	return index, nil
+}
+
+// readError returns ReadError for the error of reading the file at the given offset
+func (r *Reader) readError(offset uint64, err error) *ReadError {
+	return newReadError(r.Name(), offset, err)
+}
+
+// getNextEntry returns next entry in the queue
+func (r *Reader) getNextEntry() (*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.getNextEntryLocked()
+}
+
+// getNextEntryLocked returns next entry in the queue
+// position is not changed if the entry cannot be read
+// lock has to be held by the caller
+func (r *Reader) getNextEntryLocked() (*Entry, error) {
+	arrayOffset, itemOffset := r.nextArrayOffset, r.nextItemOffset
+
+	entryOffset, err := r.nextEntryOffsetLocked()
+	if err != nil || entryOffset == 0 {
+		return nil, err
+	}
+
+	entry, err := r.getEntry(entryOffset)
+	if err != nil {
+		r.nextArrayOffset, r.nextItemOffset = arrayOffset, itemOffset
+		return nil, r.readError(entryOffset, err)
+	}
+
+	return entry, nil
+}
+
+// skipNextEntry moves position forward over the next entry without reading it
+func (r *Reader) skipNextEntry() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.nextEntryOffsetLocked()
+	return err
+}
+
+// nextEntryOffsetLocked moves position forward and returns offset of the next entry
+// it returns 0 if there is nothing to read
+// lock has to be held by the caller
+func (r *Reader) nextEntryOffsetLocked() (uint64, error) {
+	for {
+		// there was no entry array when the position has been set
+		if r.nextArrayOffset == 0 {
+			entryArrayOffset := r.getHeader().entry_array_offset
+			if entryArrayOffset == 0 {
+				return 0, nil
+			}
+			r.nextArrayOffset = entryArrayOffset
+			r.nextItemOffset = 0
+		}
+
+		entryArray, err := r.getEntryArray(r.nextArrayOffset)
+		if err != nil {
+			return 0, r.readError(r.nextArrayOffset, err)
+		}
+
+		// move to the next entry array if the current one has been read
+		if r.nextItemOffset >= entryArray.countItems {
+			if entryArray.next_entry_array_offset == 0 {
+				return 0, nil
+			}
+			r.nextArrayOffset = entryArray.next_entry_array_offset
+			r.nextItemOffset = 0
+			continue
+		}
+
+		entryOffset := entryArray.items()[r.nextItemOffset]
+
+		// return 0 if there is nothing to read
+		if entryOffset == 0 {
+			return 0, nil
+		}
+
+		// set pointer to next element
+		r.nextItemOffset += 1
+
+		return entryOffset, nil
+	}
+}
+
+// getPreviousEntry returns previous entry in the queue
+// next call of getNextEntry returns the same entry
+func (r *Reader) getPreviousEntry() (*Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.getPreviousEntryLocked()
+}
+
+// getPreviousEntryLocked returns previous entry in the queue
+// position is not changed if the entry cannot be read
+// lock has to be held by the caller
+func (r *Reader) getPreviousEntryLocked() (*Entry, error) {
+	arrayOffset, itemOffset := r.nextArrayOffset, r.nextItemOffset
+
+	entryOffset, err := r.previousEntryOffsetLocked()
+	if err != nil || entryOffset == 0 {
+		return nil, err
+	}
+
+	entry, err := r.getEntry(entryOffset)
+	if err != nil {
+		r.nextArrayOffset, r.nextItemOffset = arrayOffset, itemOffset
+		return nil, r.readError(entryOffset, err)
+	}
+
+	return entry, nil
+}
+
+// skipPreviousEntry moves position backward over the previous entry without reading it
+func (r *Reader) skipPreviousEntry() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.previousEntryOffsetLocked()
+	return err
+}
+
+// previousEntryOffsetLocked moves position backward and returns offset of the previous entry
+// it returns 0 if the beginning of the file has been reached
+// lock has to be held by the caller
+func (r *Reader) previousEntryOffsetLocked() (uint64, error) {
+	// there is no entry array yet
+	if r.nextArrayOffset == 0 {
+		return 0, nil
+	}
+
+	for {
+		// move to the last item of the previous entry array
+		if r.nextItemOffset == 0 {
+			index, err := r.entryArrayIndexLocked(r.nextArrayOffset)
+			if err != nil {
+				return 0, r.readError(r.nextArrayOffset, err)
+			}
+
+			// this is the first entry array, so there is nothing to read
+			if index == 0 {
+				return 0, nil
+			}
+
+			entryArray, err := r.getEntryArray(r.entryArrays[index-1])
+			if err != nil {
+				return 0, r.readError(r.entryArrays[index-1], err)
+			}
+
+			r.nextArrayOffset = r.entryArrays[index-1]
+			r.nextItemOffset = len(arrayItems(entryArray))
+			continue
+		}
+
+		entryArray, err := r.getEntryArray(r.nextArrayOffset)
+		if err != nil {
+			return 0, r.readError(r.nextArrayOffset, err)
+		}
+		items := arrayItems(entryArray)
+
+		// position can point behind the last item
+		if r.nextItemOffset > len(items) {
+			r.nextItemOffset = len(items)
+			continue
+		}
+
+		// set pointer to previous element
+		r.nextItemOffset -= 1
+
+		return items[r.nextItemOffset], nil
+	}
+}
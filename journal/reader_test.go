@@ -0,0 +1,339 @@
+package journal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readMessages returns MESSAGE of the entries returned by next until io.EOF
+func readMessages(t *testing.T, next func() (Log, error)) []string {
+	messages := []string{}
+	for {
+		log, err := next()
+		if errors.Is(err, io.EOF) {
+			return messages
+		}
+		require.NoError(t, err)
+
+		message, ok := log.Get("MESSAGE")
+		require.True(t, ok)
+		messages = append(messages, message)
+	}
+}
+
+// testMessages returns MESSAGE of the given entries written by writeTestJournal
+func testMessages(indexes ...int) []string {
+	messages := []string{}
+	for _, i := range indexes {
+		messages = append(messages, fmt.Sprintf("message %d", i))
+	}
+	return messages
+}
+
+func TestReaderNextPrevious(t *testing.T) {
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{Compact: true}, 5))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, testMessages(0, 1, 2, 3, 4), readMessages(t, reader.Next))
+	assert.Equal(t, testMessages(4, 3, 2, 1, 0), readMessages(t, reader.Previous))
+
+	require.NoError(t, reader.SeekTail())
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+	log, err := reader.Previous()
+	require.NoError(t, err)
+	message, _ := log.Get("MESSAGE")
+	assert.Equal(t, "message 4", message)
+
+	reader.SeekHead()
+	_, err = reader.Previous()
+	assert.ErrorIs(t, err, io.EOF)
+	log, err = reader.Next()
+	require.NoError(t, err)
+	message, _ = log.Get("MESSAGE")
+	assert.Equal(t, "message 0", message)
+}
+
+func TestReaderSetFilter(t *testing.T) {
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{Compact: true}, 20))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	reader.SetFilter(&FilterChain{
+		Filters: []Filter{{Name: "_PID", Matches: []string{"3"}, Keep: true}},
+	})
+	assert.Equal(t, testMessages(3, 10, 17), readMessages(t, reader.Next))
+	assert.Equal(t, testMessages(17, 10, 3), readMessages(t, reader.Previous))
+
+	reader.SetFilter(nil)
+	assert.Len(t, readMessages(t, reader.Next), 20)
+}
+
+func TestReaderFindData(t *testing.T) {
+	for _, config := range []WriterConfig{{}, {Compact: true, KeyedHash: true, Compress: true}} {
+		t.Run(fmt.Sprintf("%+v", config), func(t *testing.T) {
+			reader, err := NewReader(writeTestJournal(t, config, 20))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			data, err := reader.findData([]byte("_PID=3"))
+			require.NoError(t, err)
+			require.NotNil(t, data)
+			assert.Equal(t, uint64(3), data.n_entries)
+
+			// entries are returned in the order they have been written
+			entries, err := reader.getDataEntries(data)
+			require.NoError(t, err)
+			seqnums := []uint64{}
+			for _, offset := range entries {
+				entry, err := reader.getEntry(offset)
+				require.NoError(t, err)
+				seqnums = append(seqnums, entry.seqnum)
+			}
+			assert.Equal(t, []uint64{4, 11, 18}, seqnums)
+
+			// compressed payload is found by its uncompressed value
+			data, err = reader.findData([]byte("BIG=" + strings.Repeat("x", 2000)))
+			require.NoError(t, err)
+			require.NotNil(t, data)
+			entries, err = reader.getDataEntries(data)
+			require.NoError(t, err)
+			assert.Len(t, entries, 2)
+
+			data, err = reader.findData([]byte("_PID=7"))
+			require.NoError(t, err)
+			assert.Nil(t, data)
+
+			entries, err = reader.getDataEntries(&Data{})
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestReaderFields(t *testing.T) {
+	for _, config := range []WriterConfig{{}, {Compact: true, KeyedHash: true, Compress: true}} {
+		t.Run(fmt.Sprintf("%+v", config), func(t *testing.T) {
+			reader, err := NewReader(writeTestJournal(t, config, 20))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			fields, err := reader.Fields()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"BIG", "MESSAGE", "_BOOT_ID", "_PID", "_SYSTEMD_UNIT"}, fields)
+
+			// every value is returned once, although it is used by several entries
+			values, err := reader.FieldValues("_PID")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6"}, values)
+
+			values, err = reader.FieldValues("_SYSTEMD_UNIT")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"test.service"}, values)
+
+			// value is decompressed if the file is compressed
+			values, err = reader.FieldValues("BIG")
+			require.NoError(t, err)
+			assert.Equal(t, []string{strings.Repeat("x", 2000)}, values)
+
+			values, err = reader.FieldValues("_COMM")
+			require.NoError(t, err)
+			assert.Empty(t, values)
+		})
+	}
+}
+
+func TestReaderFieldsEmpty(t *testing.T) {
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{}, 0))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	fields, err := reader.Fields()
+	require.NoError(t, err)
+	assert.Empty(t, fields)
+
+	values, err := reader.FieldValues("MESSAGE")
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestReaderPreviousArrays(t *testing.T) {
+	n := 100
+	all := []int{}
+	for i := n - 1; i >= 0; i-- {
+		all = append(all, i)
+	}
+
+	for _, compact := range []bool{false, true} {
+		t.Run(fmt.Sprintf("compact %v", compact), func(t *testing.T) {
+			reader, err := NewReader(writeTestJournal(t, WriterConfig{Compact: compact}, n))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			// arrays hold 4, 8, 26 and 78 items
+			require.NoError(t, reader.SeekTail())
+			assert.Equal(t, testMessages(all...), readMessages(t, reader.Previous))
+			require.Len(t, reader.entryArrays, 4)
+
+			// direction changes at the boundary of the arrays
+			require.NoError(t, reader.SeekSeqnum(13))
+			assert.Equal(t, testMessages(11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1, 0), readMessages(t, reader.Previous))
+			for _, i := range []int{0, 1, 2, 3, 4} {
+				log, err := reader.Next()
+				require.NoError(t, err)
+				message, _ := log.Get("MESSAGE")
+				assert.Equal(t, fmt.Sprintf("message %d", i), message)
+			}
+			log, err := reader.Previous()
+			require.NoError(t, err)
+			message, _ := log.Get("MESSAGE")
+			assert.Equal(t, "message 4", message)
+			log, err = reader.Previous()
+			require.NoError(t, err)
+			message, _ = log.Get("MESSAGE")
+			assert.Equal(t, "message 3", message)
+		})
+	}
+}
+
+func TestReaderConcurrentUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "system.journal")
+	writer, err := NewWriter(path, WriterConfig{Compact: true})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	write := func(from int, to int) error {
+		for i := from; i < to; i++ {
+			err := writer.writeEntry(uint64(1700000000000000)+uint64(i)*1000, uint64(1000+i), testBootID, testPayloads(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	require.NoError(t, write(0, 10))
+
+	reader, err := NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// the same reader is used by two goroutines, forks share its mapping
+	readers := []*Reader{reader, reader}
+	for i := 0; i < 2; i++ {
+		fork := reader.fork()
+		defer fork.Close()
+		readers = append(readers, fork)
+	}
+	readers[3].SetFilter(&FilterChain{Filters: []Filter{{Name: "_PID", Matches: []string{"3"}, Keep: true}}})
+
+	n := 300
+	done := make(chan struct{})
+	errs := make(chan error, len(readers)+1)
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		errs <- write(10, n)
+	}()
+
+	for i, r := range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; ; j++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				if err := r.loadHeader(); err != nil {
+					errs <- err
+					return
+				}
+
+				var err error
+				switch j % 4 {
+				case 0:
+					err = r.SeekSeqnum(uint64(j % n))
+				case 1:
+					err = r.SeekTail()
+				case 2:
+					err = r.SeekRealtime(time.UnixMicro(int64(1700000000000000) + int64(j%n)*1000))
+				}
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				next := r.Next
+				if j%2 == 1 {
+					next = r.Previous
+				}
+				for k := 0; k < 10; k++ {
+					log, err := next()
+					if errors.Is(err, io.EOF) {
+						break
+					}
+					if err != nil {
+						errs <- err
+						return
+					}
+
+					message, _ := log.Get("MESSAGE")
+					pid, _ := log.Get("_PID")
+					if !strings.HasPrefix(message, "message ") || i == 3 && pid != "3" {
+						errs <- fmt.Errorf("unexpected entry %s with _PID %s", message, pid)
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// all entries are read once the writer is done
+	expected := []int{}
+	for i := 0; i < n; i++ {
+		expected = append(expected, i)
+	}
+	for _, r := range readers[:3] {
+		require.NoError(t, r.loadHeader())
+		r.SeekHead()
+		assert.Equal(t, testMessages(expected...), readMessages(t, r.Next))
+	}
+}
+
+func TestReaderMaxDataSize(t *testing.T) {
+	for _, config := range []WriterConfig{{}, {Compact: true, Compress: true}} {
+		reader, err := NewReader(writeTestJournal(t, config, 2))
+		require.NoError(t, err)
+		defer reader.Close()
+
+		// payload of BIG field of the first entry has 2004 bytes
+		reader.MaxDataSize = 2003
+		_, err = reader.Next()
+		assert.Error(t, err)
+
+		reader.MaxDataSize = 2004
+		reader.SeekHead()
+		assert.Equal(t, testMessages(0, 1), readMessages(t, reader.Next))
+	}
+}
@@ -0,0 +1,379 @@
+package journal
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// This implementation base on https://github.com/systemd/systemd/blob/main/src/libsystemd/sd-journal/fsprg.c
+// and https://github.com/systemd/systemd/blob/main/src/libsystemd/sd-journal/journal-verify.c
+
+// Forward Secure Pseudo Random Generator parameters used by systemd
+const (
+	FSPRG_RECOMMENDED_SECPAR  = 1536
+	FSPRG_RECOMMENDED_SEEDLEN = 96 / 8
+
+	RND_GEN_P = 0x01
+	RND_GEN_Q = 0x02
+	RND_GEN_X = 0x03
+
+	// size of the encoded secpar at the beginning of the state
+	FSPRG_STATE_HEADER_SIZE = 2
+)
+
+// TAG_OBJECT_SIZE is size of the tag object: object header, seqnum, epoch and tag
+const TAG_OBJECT_SIZE = OBJECT_HEADER_SIZE + 8 + 8 + TAG_LENGTH
+
+// SEALED_HEADER_SIZE is size of the beginning of the header, which contains all fields covered by the seal
+const SEALED_HEADER_SIZE = 136
+
+var errSealMismatch = errors.New("tag failed verification")
+
+// VerificationKey is parsed verification key as printed by journalctl --setup-keys
+// format is seed in hex (dashes are ignored), slash and start-interval in hex
+type VerificationKey struct {
+	seed []byte
+
+	// start of the first epoch in usec
+	start uint64
+	// length of the epoch in usec
+	interval uint64
+}
+
+// ParseVerificationKey parses the verification key
+func ParseVerificationKey(key string) (*VerificationKey, error) {
+	seedHex, timing, found := strings.Cut(key, "/")
+	if !found {
+		return nil, errors.New("verification key has no '/' separator")
+	}
+
+	seed := make([]byte, 0, FSPRG_RECOMMENDED_SEEDLEN)
+	seedHex = strings.ReplaceAll(seedHex, "-", "")
+	if len(seedHex) != 2*FSPRG_RECOMMENDED_SEEDLEN {
+		return nil, fmt.Errorf("verification key seed has invalid length (%d)", len(seedHex)/2)
+	}
+	for i := 0; i < len(seedHex); i += 2 {
+		value, err := strconv.ParseUint(seedHex[i:i+2], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("verification key seed is invalid: %w", err)
+		}
+		seed = append(seed, uint8(value))
+	}
+
+	startHex, intervalHex, found := strings.Cut(timing, "-")
+	if !found {
+		return nil, errors.New("verification key has no start-interval part")
+	}
+	start, err := strconv.ParseUint(startHex, 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("verification key start is invalid: %w", err)
+	}
+	interval, err := strconv.ParseUint(intervalHex, 16, 64)
+	if err != nil {
+		return nil, fmt.Errorf("verification key interval is invalid: %w", err)
+	}
+	if interval == 0 {
+		return nil, errors.New("verification key interval is zero")
+	}
+
+	return &VerificationKey{
+		seed:     seed,
+		start:    start * interval,
+		interval: interval,
+	}, nil
+}
+
+// epoch returns epoch of the given realtime (usec), realtime before the start belongs to the first epoch
+func (vk *VerificationKey) epoch(realtime uint64) uint64 {
+	if realtime < vk.start {
+		return 0
+	}
+	return (realtime - vk.start) / vk.interval
+}
+
+// epochRealtime returns realtime (usec) of the beginning of the epoch
+func (vk *VerificationKey) epochRealtime(epoch uint64) uint64 {
+	return vk.start + epoch*vk.interval
+}
+
+// detRandomize generates deterministic pseudo random bytes out of the seed
+// output is concatenation of sha256(seed || idx || counter) blocks
+func detRandomize(size int, seed []byte, idx uint32) []byte {
+	output := make([]byte, 0, size+sha256.Size)
+
+	prefix := binary.BigEndian.AppendUint32(bytes.Clone(seed), idx)
+	for counter := uint32(0); len(output) < size; counter++ {
+		sum := sha256.Sum256(binary.BigEndian.AppendUint32(prefix, counter))
+		output = append(output, sum[:]...)
+	}
+
+	return output[:size]
+}
+
+// genPrime3Mod4 generates deterministic prime p, such as p = 3 mod 4
+func genPrime3Mod4(bits int, seed []byte, idx uint32) *big.Int {
+	buffer := detRandomize(bits/8, seed, idx)
+	// set upper two bits, so that n=pq has maximum size
+	buffer[0] |= 0xc0
+	// set lower two bits, to have result 3 mod 4
+	buffer[len(buffer)-1] |= 0x03
+
+	p := new(big.Int).SetBytes(buffer)
+	four := big.NewInt(4)
+	for !p.ProbablyPrime(20) {
+		p.Add(p, four)
+	}
+
+	return p
+}
+
+// genSquare generates deterministic quadratic residue modulo n
+func genSquare(n *big.Int, seed []byte, idx uint32, secpar int) *big.Int {
+	buffer := detRandomize(secpar/8, seed, idx)
+	// clear upper bit, so that we have x < n
+	buffer[0] &= 0x7f
+
+	x := new(big.Int).SetBytes(buffer)
+	return x.Mul(x, x).Mod(x, n)
+}
+
+// fsprg is Forward Secure Pseudo Random Generator state derived from the seed
+type fsprg struct {
+	secpar int
+
+	p *big.Int
+	q *big.Int
+	n *big.Int
+	x *big.Int
+}
+
+// newFSPRG derives generator out of the verification key seed
+func newFSPRG(seed []byte) *fsprg {
+	secpar := FSPRG_RECOMMENDED_SECPAR
+	p := genPrime3Mod4(secpar/2, seed, RND_GEN_P)
+	q := genPrime3Mod4(secpar/2, seed, RND_GEN_Q)
+	n := new(big.Int).Mul(p, q)
+
+	return &fsprg{
+		secpar: secpar,
+		p:      p,
+		q:      q,
+		n:      n,
+		x:      genSquare(n, seed, RND_GEN_X, secpar),
+	}
+}
+
+// state returns serialized state for the epoch (secpar || n || x^(2^epoch) || epoch)
+// x^(2^epoch) mod n is computed separately modulo p and q and composed using CRT
+func (g *fsprg) state(epoch uint64) []byte {
+	e := new(big.Int).SetUint64(epoch)
+	two := big.NewInt(2)
+	one := big.NewInt(1)
+
+	// 2^epoch mod phi(p), and the same for q
+	kp := new(big.Int).Exp(two, e, new(big.Int).Sub(g.p, one))
+	kq := new(big.Int).Exp(two, e, new(big.Int).Sub(g.q, one))
+
+	xp := new(big.Int).Mod(g.x, g.p)
+	xp.Exp(xp, kp, g.p)
+	xq := new(big.Int).Mod(g.x, g.q)
+	xq.Exp(xq, kq, g.q)
+
+	// x = xp + p * ((xq - xp) / p mod q)
+	a := new(big.Int).Sub(xq, xp)
+	a.Mod(a, g.q)
+	a.Mul(a, new(big.Int).ModInverse(g.p, g.q))
+	a.Mod(a, g.q)
+	x := a.Mul(a, g.p).Add(a, xp)
+
+	size := g.secpar / 8
+	state := make([]byte, FSPRG_STATE_HEADER_SIZE+2*size+8)
+	binary.BigEndian.PutUint16(state[0:FSPRG_STATE_HEADER_SIZE], uint16(g.secpar/16-1))
+	g.n.FillBytes(state[FSPRG_STATE_HEADER_SIZE : FSPRG_STATE_HEADER_SIZE+size])
+	x.FillBytes(state[FSPRG_STATE_HEADER_SIZE+size : FSPRG_STATE_HEADER_SIZE+2*size])
+	binary.BigEndian.PutUint64(state[FSPRG_STATE_HEADER_SIZE+2*size:], epoch)
+
+	return state
+}
+
+// key returns HMAC key for the epoch
+// like FSPRG_GetKey, the key is derived from the state without the secpar header
+func (g *fsprg) key(epoch uint64) []byte {
+	return detRandomize(TAG_LENGTH, g.state(epoch)[FSPRG_STATE_HEADER_SIZE:], 0)
+}
+
+// SealReport is result of the seal verification
+type SealReport struct {
+	// number of verified tags
+	Tags int
+
+	// realtime of the first and the last entry in the file
+	FirstRealtime uint64
+	LastRealtime  uint64
+
+	// realtime of the last entry covered by a valid tag
+	LastSealedRealtime uint64
+
+	// region of the file which failed verification, set if Tampered is true
+	Tampered     bool
+	TamperedFrom uint64
+	TamperedTo   uint64
+}
+
+// writeSealedHeader writes to the HMAC parts of the file header covered by the seal
+// fields which change while the file is written are skipped
+func writeSealedHeader(mac hash.Hash, data []byte) {
+	// signature..state, file_id..tail_entry_boot_id, seqnum_id..arena_size
+	// and data_hash_table_offset..tail_object_offset
+	mac.Write(data[0:16])
+	mac.Write(data[24:56])
+	mac.Write(data[72:96])
+	mac.Write(data[104:136])
+}
+
+// writeSealedObject writes to the HMAC parts of the object covered by the seal
+// offsets which can change after the object has been written are skipped
+// it returns error if the object cannot be decoded
+func writeSealedObject(mac hash.Hash, oh *ObjectHeader, compact bool) error {
+	mac.Write(oh.headerBytes())
+
+	switch oh.objectType {
+	case OBJECT_DATA:
+		data, err := oh.Data(compact)
+		if err != nil {
+			return err
+		}
+		mac.Write(oh.payload[0:8])
+		mac.Write(data.payload)
+	case OBJECT_FIELD:
+		field, err := oh.Field()
+		if err != nil {
+			return err
+		}
+		mac.Write(oh.payload[0:8])
+		mac.Write(field.payload)
+	case OBJECT_ENTRY:
+		mac.Write(oh.payload)
+	case OBJECT_TAG:
+		if _, err := oh.Tag(); err != nil {
+			return err
+		}
+		mac.Write(oh.payload[0:16])
+	}
+
+	return nil
+}
+
+// sealMAC computes HMAC of the objects between from and to (inclusive) using the epoch key
+// the file header is included for the first tag
+func (r *Reader) sealMAC(key []byte, from uint64, to uint64, first bool) ([]byte, error) {
+	mac := hmac.New(sha256.New, key)
+	if first {
+		data, err := r.mapping.read(0, SEALED_HEADER_SIZE)
+		if err != nil {
+			return nil, err
+		}
+		writeSealedHeader(mac, data)
+	}
+
+	for offset := from; offset <= to; {
+		oh, err := r.getObject(offset)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeSealedObject(mac, oh, r.isCompact()); err != nil {
+			return nil, fmt.Errorf("cannot read object at %d: %w", offset, err)
+		}
+		offset += align64(oh.size)
+	}
+
+	return mac.Sum(nil), nil
+}
+
+// VerifySeal verifies the tag objects of sealed file using the verification key
+// it returns report which contains the last trustworthy timestamp and tampered region if any
+func (r *Reader) VerifySeal(key *VerificationKey) (*SealReport, error) {
+	header := r.getHeader()
+	if header.compatible_flags&HEADER_COMPATIBLE_SEALED == 0 {
+		return nil, errors.New("file is not sealed")
+	}
+
+	report := &SealReport{
+		FirstRealtime: header.head_entry_realtime,
+		LastRealtime:  header.tail_entry_realtime,
+	}
+	generator := newFSPRG(key.seed)
+
+	lastTag := uint64(0)
+	lastEpoch := uint64(0)
+	entryRealtime := uint64(0)
+	entryRealtimeSet := false
+
+	for offset := header.header_size; offset != 0 && offset <= header.tail_object_offset; {
+		oh, err := r.getObject(offset)
+		if err != nil {
+			return report, fmt.Errorf("cannot read object at %d: %w", offset, err)
+		}
+
+		switch oh.objectType {
+		case OBJECT_ENTRY:
+			entry, err := oh.Entry(r.isCompact())
+			if err != nil {
+				return report, fmt.Errorf("cannot read object at %d: %w", offset, err)
+			}
+			entryRealtime = entry.realtime
+			entryRealtimeSet = true
+		case OBJECT_TAG:
+			if oh.size != TAG_OBJECT_SIZE {
+				return report, fmt.Errorf("tag object at %d has invalid size (%d)", offset, oh.size)
+			}
+			tag, err := oh.Tag()
+			if err != nil {
+				return report, fmt.Errorf("cannot read object at %d: %w", offset, err)
+			}
+
+			if tag.seqnum != uint64(report.Tags)+1 {
+				return report, fmt.Errorf("tag sequence number out of synchronization at %d", offset)
+			}
+			if tag.epoch < lastEpoch {
+				return report, fmt.Errorf("epoch sequence out of synchronization at %d", offset)
+			}
+			if entryRealtimeSet && entryRealtime >= key.epochRealtime(tag.epoch)+key.interval {
+				return report, fmt.Errorf("tag/entry realtime timestamp out of synchronization at %d", offset)
+			}
+
+			// HMAC covers everything since the last tag
+			from := lastTag
+			if lastTag == 0 {
+				from = header.header_size
+			}
+			sum, err := r.sealMAC(generator.key(tag.epoch), from, offset, lastTag == 0)
+			if err != nil {
+				return report, err
+			}
+			if !hmac.Equal(sum, tag.tag[:]) {
+				report.Tampered = true
+				report.TamperedFrom = from
+				report.TamperedTo = offset + align64(oh.size)
+				return report, fmt.Errorf("%w at %d", errSealMismatch, offset)
+			}
+
+			report.LastSealedRealtime = entryRealtime
+			lastTag = offset + align64(oh.size)
+			lastEpoch = tag.epoch
+			report.Tags++
+		}
+
+		offset += align64(oh.size)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,245 @@
+package journal
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVerificationKey(t *testing.T) {
+	testCases := []struct {
+		name        string
+		key         string
+		expected    *VerificationKey
+		expectedErr bool
+	}{
+		{
+			name: "journalctl key",
+			key:  "1a769e-5b3f64-815bb5-fb6b76/155d5b58-4c4b40",
+			expected: &VerificationKey{
+				seed:     []byte{0x1a, 0x76, 0x9e, 0x5b, 0x3f, 0x64, 0x81, 0x5b, 0xb5, 0xfb, 0x6b, 0x76},
+				start:    1792198840000000,
+				interval: 5000000,
+			},
+		},
+		{
+			name: "without dashes",
+			key:  "1a769e5b3f64815bb5fb6b76/155d5b58-4c4b40",
+			expected: &VerificationKey{
+				seed:     []byte{0x1a, 0x76, 0x9e, 0x5b, 0x3f, 0x64, 0x81, 0x5b, 0xb5, 0xfb, 0x6b, 0x76},
+				start:    1792198840000000,
+				interval: 5000000,
+			},
+		},
+		{
+			name:        "no separator",
+			key:         "1a769e-5b3f64-815bb5-fb6b76",
+			expectedErr: true,
+		},
+		{
+			name:        "short seed",
+			key:         "1a769e-5b3f64-815bb5/155d5b58-4c4b40",
+			expectedErr: true,
+		},
+		{
+			name:        "invalid seed",
+			key:         "1a769e-5b3f64-815bb5-fb6bzz/155d5b58-4c4b40",
+			expectedErr: true,
+		},
+		{
+			name:        "no interval",
+			key:         "1a769e-5b3f64-815bb5-fb6b76/155d5b58",
+			expectedErr: true,
+		},
+		{
+			name:        "zero interval",
+			key:         "1a769e-5b3f64-815bb5-fb6b76/155d5b58-0",
+			expectedErr: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParseVerificationKey(tt.key)
+			if tt.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, key)
+		})
+	}
+}
+
+func TestDetRandomize(t *testing.T) {
+	// sha256("seed" || 00000007 || 00000000) || sha256("seed" || 00000007 || 00000001) truncated to 40 bytes
+	expected := "5a05154090d636e56e2743f5ed9e1d76aeb7cbafb5f59b06f6e25fa9750f3e074d5b4da159213e46"
+	assert.Equal(t, expected, hex.EncodeToString(detRandomize(40, []byte("seed"), 7)))
+}
+
+func TestFSPRGKey(t *testing.T) {
+	// states and keys computed by FSPRG_Seek and FSPRG_GetKey of systemd 252 (fsprg.c) for the same seed
+	key, err := ParseVerificationKey("1a769e-5b3f64-815bb5-fb6b76/155d5b58-4c4b40")
+	require.NoError(t, err)
+	generator := newFSPRG(key.seed)
+
+	state := generator.state(3)
+	require.Len(t, state, 2+2*FSPRG_RECOMMENDED_SECPAR/8+8)
+	assert.Equal(t, []byte{0x00, 0x5f}, state[0:FSPRG_STATE_HEADER_SIZE])
+	stateSum := sha256.Sum256(state)
+	assert.Equal(t, "7da744085c8fa015476c63f875cb5ad0d3658a0b5f8c1d352c6c0cf186b367d1", hex.EncodeToString(stateSum[:]))
+
+	testCases := []struct {
+		epoch    uint64
+		expected string
+	}{
+		{
+			epoch:    0,
+			expected: "1eb30d1625278d0ff22fef3593a46e59f27410f46915633cb7b19d2c21fc407a",
+		},
+		{
+			epoch:    1,
+			expected: "46bf7bd1fbaabebf54eb0562e5fe15a9df725d08a325955e28fea20b36e0acf7",
+		},
+		{
+			epoch:    3,
+			expected: "7d0d6b91996eb7ffdde40af226ea821e2bc6dde00da1db7081e92b08ab0f5433",
+		},
+		{
+			epoch:    1000,
+			expected: "9ebb447461216b1c328297e973bf7285f0f4ce9a4fddcde22450f96930b8341e",
+		},
+	}
+	for _, tt := range testCases {
+		assert.Equal(t, tt.expected, hex.EncodeToString(generator.key(tt.epoch)))
+	}
+}
+
+func TestTag(t *testing.T) {
+	payload, err := hex.DecodeString("0100000000000000000000000000000018b3abd74fd60b22638b102ce8351c1e3a54dc4a4b98ed29a459ce55bafa15b2")
+	require.NoError(t, err)
+
+	header := ObjectHeader{objectType: OBJECT_TAG, size: TAG_OBJECT_SIZE}
+	header.setPayload(payload)
+	tag, err := header.Tag()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1), tag.seqnum)
+	assert.Equal(t, uint64(0), tag.epoch)
+	assert.Equal(t, "18b3abd74fd60b22638b102ce8351c1e3a54dc4a4b98ed29a459ce55bafa15b2", hex.EncodeToString(tag.tag[:]))
+}
+
+// TEST_SEAL_KEY starts at realtime of the first entry of writeTestJournal and epoch lasts 10 entries
+const TEST_SEAL_KEY = "1a769e-5b3f64-815bb5-fb6b76/2794ca2400-2710"
+
+func TestVerifySeal(t *testing.T) {
+	key, err := ParseVerificationKey(TEST_SEAL_KEY)
+	require.NoError(t, err)
+
+	for _, config := range []WriterConfig{{SealKey: key}, {SealKey: key, Compact: true, KeyedHash: true, Compress: true}} {
+		reader, err := NewReader(writeTestJournal(t, config, 30))
+		require.NoError(t, err)
+		defer reader.Close()
+		require.NoError(t, reader.Verify())
+
+		// the first tag seals the header, then every epoch is sealed and the last one on close
+		report, err := reader.VerifySeal(key)
+		require.NoError(t, err)
+		assert.Equal(t, &SealReport{
+			Tags:               4,
+			FirstRealtime:      1700000000000000,
+			LastRealtime:       1700000000029000,
+			LastSealedRealtime: 1700000000029000,
+		}, report)
+		assert.Equal(t, uint64(4), reader.getHeader().n_tags)
+	}
+}
+
+func TestVerifySealTampered(t *testing.T) {
+	key, err := ParseVerificationKey(TEST_SEAL_KEY)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		// offset of the modified byte
+		offset func(t *testing.T, path string) uint64
+		// index of the tag which fails verification
+		tag int
+	}{
+		{
+			name:   "header",
+			offset: func(t *testing.T, path string) uint64 { return 40 },
+			tag:    0,
+		},
+		{
+			name: "data of the entry of the second epoch",
+			offset: func(t *testing.T, path string) uint64 {
+				// digit of the payload following the fields of regular Data object
+				return dataOffset(t, path, 16, "MESSAGE=message 15") + OBJECT_HEADER_SIZE + 48 + 16
+			},
+			tag: 2,
+		},
+		{
+			name: "tag sealing the last epoch",
+			offset: func(t *testing.T, path string) uint64 {
+				reader, err := NewReader(path)
+				require.NoError(t, err)
+				defer reader.Close()
+				return objectOffsets(t, reader, OBJECT_TAG)[3] + TAG_OBJECT_SIZE - 1
+			},
+			tag: 3,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestJournal(t, WriterConfig{SealKey: key}, 30)
+
+			offset := tt.offset(t, path)
+			file, err := os.OpenFile(path, os.O_RDWR, 0)
+			require.NoError(t, err)
+			value := []byte{0}
+			_, err = file.ReadAt(value, int64(offset))
+			require.NoError(t, err)
+			_, err = file.WriteAt([]byte{value[0] ^ 0x01}, int64(offset))
+			require.NoError(t, err)
+			require.NoError(t, file.Close())
+
+			reader, err := NewReader(path)
+			require.NoError(t, err)
+			defer reader.Close()
+
+			report, err := reader.VerifySeal(key)
+			assert.ErrorIs(t, err, errSealMismatch)
+
+			// region starts after the last valid tag and ends with the failed one
+			tags := objectOffsets(t, reader, OBJECT_TAG)
+			from := reader.getHeader().header_size
+			if tt.tag > 0 {
+				from = tags[tt.tag-1] + TAG_OBJECT_SIZE
+			}
+			assert.Equal(t, tt.tag, report.Tags)
+			assert.True(t, report.Tampered)
+			assert.Equal(t, from, report.TamperedFrom)
+			assert.Equal(t, tags[tt.tag]+TAG_OBJECT_SIZE, report.TamperedTo)
+			if tt.tag > 0 {
+				assert.GreaterOrEqual(t, offset, report.TamperedFrom)
+			}
+			assert.Less(t, offset, report.TamperedTo)
+		})
+	}
+}
+
+func TestVerifySealNotSealed(t *testing.T) {
+	key, err := ParseVerificationKey(TEST_SEAL_KEY)
+	require.NoError(t, err)
+
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{}, 1))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	_, err = reader.VerifySeal(key)
+	assert.Error(t, err)
+}
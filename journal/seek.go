@@ -0,0 +1,290 @@
+package journal
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// arrayItems returns non-zero items of the EntryArray
+// entry arrays are filled from the beginning, so first zero item ends the list
+func arrayItems(entryArray *EntryArray) []uint64 {
+	items := entryArray.items()
+	for i, item := range items {
+		if item == 0 {
+			return items[:i]
+		}
+	}
+	return items
+}
+
+// bisectItems returns index of the first item for which before returns false
+// before has to be false for the last item
+func (r *Reader) bisectItems(items []uint64, before func(*Entry) bool) (int, error) {
+	low, high := 0, len(items)-1
+	for low < high {
+		middle := low + (high-low)/2
+
+		entry, err := r.getEntry(items[middle])
+		if err != nil {
+			return 0, err
+		}
+
+		if before(entry) {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+
+	return low, nil
+}
+
+// seekEntry sets position right before the first entry for which before returns false
+func (r *Reader) seekEntry(before func(*Entry) bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.seekEntryLocked(before)
+}
+
+// seekEntryLocked sets position right before the first entry for which before returns false
+// lock has to be held by the caller
+func (r *Reader) seekEntryLocked(before func(*Entry) bool) error {
+	return r.seekItemLocked(func(entryOffset uint64) (bool, error) {
+		entry, err := r.getEntry(entryOffset)
+		if err != nil {
+			return false, err
+		}
+		return before(entry), nil
+	})
+}
+
+// seekOffsetLocked sets position right before the first entry stored at the offset or after it
+// objects are appended to the file, so offsets of entries grow along the chain and no entry is read
+// lock has to be held by the caller
+func (r *Reader) seekOffsetLocked(offset uint64) error {
+	return r.seekItemLocked(func(entryOffset uint64) (bool, error) {
+		return entryOffset < offset, nil
+	})
+}
+
+// bisect returns the lowest index lower than n for which before returns false and n if there is no such index
+func bisect(n int, before func(i int) (bool, error)) (int, error) {
+	low, high := 0, n
+	for low < high {
+		middle := low + (high-low)/2
+
+		ok, err := before(middle)
+		if err != nil {
+			return 0, err
+		}
+
+		if ok {
+			low = middle + 1
+		} else {
+			high = middle
+		}
+	}
+
+	return low, nil
+}
+
+// seekItemLocked sets position right before the first entry for which before returns false
+// entries are expected to be sorted, so the cached entry arrays are bisected by their first items
+// and then the items of the selected array, only single items are read from the file
+// lock has to be held by the caller
+func (r *Reader) seekItemLocked(before func(entryOffset uint64) (bool, error)) error {
+	err := r.updateEntryArraysLocked()
+	if err != nil {
+		return err
+	}
+
+	r.resetOffsetLocked()
+	if len(r.entryArrays) == 0 {
+		return nil
+	}
+
+	// empty items end the chain, so they are never before the entry
+	itemBefore := func(arrayOffset uint64, index int) (bool, error) {
+		item, err := r.entryArrayItem(arrayOffset, index)
+		if err != nil || item == 0 {
+			return false, err
+		}
+		return before(item)
+	}
+
+	// the entry is in the last array starting before it
+	arrayIndex, err := bisect(len(r.entryArrays), func(i int) (bool, error) {
+		return itemBefore(r.entryArrays[i], 0)
+	})
+	if err != nil {
+		return err
+	}
+	if arrayIndex == 0 {
+		r.nextArrayOffset = r.entryArrays[0]
+		return nil
+	}
+
+	arrayOffset := r.entryArrays[arrayIndex-1]
+	capacity, err := r.entryArrayCapacity(arrayOffset)
+	if err != nil {
+		return err
+	}
+
+	index, err := bisect(capacity, func(i int) (bool, error) {
+		return itemBefore(arrayOffset, i)
+	})
+	if err != nil {
+		return err
+	}
+
+	r.nextArrayOffset = arrayOffset
+	r.nextItemOffset = index
+	return nil
+}
+
+// entryArrayCapacity returns number of items, including the empty ones, of the entry array
+// only the object header is read
+func (r *Reader) entryArrayCapacity(arrayOffset uint64) (int, error) {
+	headerData, err := r.mapping.read(arrayOffset, OBJECT_HEADER_SIZE)
+	if err != nil {
+		return 0, err
+	}
+
+	oh, err := newObjectHeader(headerData)
+	if err != nil {
+		return 0, err
+	}
+	if oh.objectType != OBJECT_ENTRY_ARRAY || oh.size < OBJECT_HEADER_SIZE+8 {
+		return 0, fmt.Errorf("object at %d is not an entry array object (%d)", arrayOffset, oh.objectType)
+	}
+
+	return int((oh.size - OBJECT_HEADER_SIZE - 8) / entryArrayItemSize(r.isCompact())), nil
+}
+
+// entryArrayItem returns the item of the entry array without reading the whole array
+// index has to be lower than capacity of the array
+func (r *Reader) entryArrayItem(arrayOffset uint64, index int) (uint64, error) {
+	itemSize := entryArrayItemSize(r.isCompact())
+	data, err := r.mapping.read(arrayOffset+OBJECT_HEADER_SIZE+8+uint64(index)*itemSize, itemSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if itemSize == 4 {
+		return uint64(le32(([4]byte)(data))), nil
+	}
+	return le64(([8]byte)(data)), nil
+}
+
+// SeekRealtime sets position right before the first entry written at given time or later
+func (r *Reader) SeekRealtime(t time.Time) error {
+	realtime := uint64(t.UnixMicro())
+
+	return r.seekEntry(func(entry *Entry) bool {
+		return entry.realtime < realtime
+	})
+}
+
+// SeekSeqnum sets position right before the entry with given sequence number
+// or the first entry after it if there is no such entry
+func (r *Reader) SeekSeqnum(seqnum uint64) error {
+	return r.seekEntry(func(entry *Entry) bool {
+		return entry.seqnum < seqnum
+	})
+}
+
+// bisectDataEntries returns the first entry referencing the Data object for which before returns false
+// it returns nil if there is no such entry and the last entry referencing the Data object as the second value
+func (r *Reader) bisectDataEntries(data *Data, before func(*Entry) bool) (*Entry, *Entry, error) {
+	if data.n_entries == 0 {
+		return nil, nil, nil
+	}
+
+	nEntries := data.n_entries
+	arrayOffset := data.entry_array_offset
+
+	// first entry is stored inline
+	last, err := r.getEntry(data.entry_offset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !before(last) {
+		return last, nil, nil
+	}
+
+	// rest of them are stored in the chain of entry arrays
+	count := uint64(1)
+	for arrayOffset != 0 && count < nEntries {
+		entryArray, err := r.getEntryArray(arrayOffset)
+		if err != nil {
+			return nil, nil, err
+		}
+		items := arrayItems(entryArray)
+
+		if uint64(len(items)) > nEntries-count {
+			items = items[:nEntries-count]
+		}
+		if len(items) == 0 {
+			break
+		}
+		count += uint64(len(items))
+		arrayOffset = entryArray.next_entry_array_offset
+
+		last, err = r.getEntry(items[len(items)-1])
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// the entry is not in this array, so check the next one
+		if before(last) {
+			continue
+		}
+
+		index, err := r.bisectItems(items, before)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		entry, err := r.getEntry(items[index])
+		if err != nil {
+			return nil, nil, err
+		}
+		return entry, nil, nil
+	}
+
+	return nil, last, nil
+}
+
+// SeekMonotonic sets position right before the first entry of the given boot
+// with monotonic timestamp equal or greater than usec
+func (r *Reader) SeekMonotonic(bootID [16]byte, usec uint64) error {
+	// entries of the boot are found using _BOOT_ID Data object
+	data, err := r.findData([]byte("_BOOT_ID=" + hex.EncodeToString(bootID[:])))
+	if err != nil {
+		return err
+	}
+
+	if data == nil {
+		return fmt.Errorf("there is no entry for boot %x", bootID)
+	}
+
+	entry, last, err := r.bisectDataEntries(data, func(entry *Entry) bool {
+		return entry.monotonic < usec
+	})
+	if err != nil {
+		return err
+	}
+
+	// all entries of the boot are older, so set position right after the last one
+	if entry == nil {
+		if last == nil {
+			return fmt.Errorf("there is no entry for boot %x", bootID)
+		}
+		return r.SeekSeqnum(last.seqnum + 1)
+	}
+
+	// sequence numbers are growing within the file, so seek by seqnum
+	return r.SeekSeqnum(entry.seqnum)
+}
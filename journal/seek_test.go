@@ -0,0 +1,214 @@
+package journal
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaderSeek(t *testing.T) {
+	n := 100
+	// entries of writeTestJournal by their index, n means that the position is right after the last entry
+	testCases := []struct {
+		name     string
+		seek     func(reader *Reader) error
+		expected int
+	}{
+		{
+			name:     "realtime of the first entry",
+			seek:     func(reader *Reader) error { return reader.SeekRealtime(time.UnixMicro(1700000000000000)) },
+			expected: 0,
+		},
+		{
+			name:     "realtime before the first entry",
+			seek:     func(reader *Reader) error { return reader.SeekRealtime(time.UnixMicro(0)) },
+			expected: 0,
+		},
+		{
+			name:     "realtime between entries",
+			seek:     func(reader *Reader) error { return reader.SeekRealtime(time.UnixMicro(1700000000000000 + 41*1000 + 1)) },
+			expected: 42,
+		},
+		{
+			name:     "realtime after the last entry",
+			seek:     func(reader *Reader) error { return reader.SeekRealtime(time.UnixMicro(1800000000000000)) },
+			expected: n,
+		},
+		{
+			name:     "seqnum at the beginning of the second array",
+			seek:     func(reader *Reader) error { return reader.SeekSeqnum(5) },
+			expected: 4,
+		},
+		{
+			name:     "seqnum at the end of the third array",
+			seek:     func(reader *Reader) error { return reader.SeekSeqnum(38) },
+			expected: 37,
+		},
+		{
+			name:     "seqnum at the beginning of the fourth array",
+			seek:     func(reader *Reader) error { return reader.SeekSeqnum(39) },
+			expected: 38,
+		},
+		{
+			name:     "seqnum of the last entry",
+			seek:     func(reader *Reader) error { return reader.SeekSeqnum(uint64(n)) },
+			expected: n - 1,
+		},
+		{
+			name:     "monotonic",
+			seek:     func(reader *Reader) error { return reader.SeekMonotonic(testBootID, 1000+77) },
+			expected: 77,
+		},
+		{
+			name:     "monotonic after the last entry of the boot",
+			seek:     func(reader *Reader) error { return reader.SeekMonotonic(testBootID, 1000000) },
+			expected: n,
+		},
+	}
+	for _, compact := range []bool{false, true} {
+		reader, err := NewReader(writeTestJournal(t, WriterConfig{Compact: compact}, n))
+		require.NoError(t, err)
+		defer reader.Close()
+
+		// arrays hold 4, 8, 26 and 78 items, so entries span several of them
+		require.Len(t, reader.entryArrays, 4)
+
+		for _, tt := range testCases {
+			t.Run(fmt.Sprintf("%s compact %v", tt.name, compact), func(t *testing.T) {
+				require.NoError(t, tt.seek(reader))
+
+				log, err := reader.Next()
+				if tt.expected == n {
+					assert.ErrorIs(t, err, io.EOF)
+				} else {
+					require.NoError(t, err)
+					message, _ := log.Get("MESSAGE")
+					assert.Equal(t, fmt.Sprintf("message %d", tt.expected), message)
+				}
+
+				// the same position is reached from the other side
+				require.NoError(t, tt.seek(reader))
+				log, err = reader.Previous()
+				if tt.expected == 0 {
+					assert.ErrorIs(t, err, io.EOF)
+				} else {
+					require.NoError(t, err)
+					message, _ := log.Get("MESSAGE")
+					assert.Equal(t, fmt.Sprintf("message %d", tt.expected-1), message)
+				}
+			})
+		}
+	}
+}
+
+func TestReaderSeekEmpty(t *testing.T) {
+	reader, err := NewReader(writeTestJournal(t, WriterConfig{}, 0))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, reader.SeekSeqnum(1))
+	_, err = reader.Next()
+	assert.ErrorIs(t, err, io.EOF)
+
+	assert.Error(t, reader.SeekMonotonic(testBootID, 0))
+}
+
+func TestReaderSeekTail(t *testing.T) {
+	n := 100
+	testCases := []struct {
+		name       string
+		config     WriterConfig
+		fromHeader bool
+	}{
+		{
+			name:       "tail entry offset in the header",
+			config:     WriterConfig{},
+			fromHeader: true,
+		},
+		{
+			name:       "compact with tail entry offset in the header",
+			config:     WriterConfig{Compact: true},
+			fromHeader: true,
+		},
+		{
+			name:       "tail entry array in the header",
+			config:     WriterConfig{HeaderSize: 264},
+			fromHeader: true,
+		},
+		{
+			name:   "header without tail entry array",
+			config: WriterConfig{HeaderSize: 256},
+		},
+		{
+			name:   "header without hash chain depths",
+			config: WriterConfig{HeaderSize: 240},
+		},
+		{
+			name:   "header without counters",
+			config: WriterConfig{HeaderSize: 208},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, err := NewReader(writeTestJournal(t, tt.config, n))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			reader.mu.Lock()
+			ok, err := reader.seekTailFromHeaderLocked()
+			reader.mu.Unlock()
+			require.NoError(t, err)
+			assert.Equal(t, tt.fromHeader, ok)
+
+			// chain of entry arrays is walked only if the header doesn't point to the tail
+			reader.entryArrays = nil
+			require.NoError(t, reader.SeekTail())
+			if tt.fromHeader {
+				assert.Empty(t, reader.entryArrays)
+			} else {
+				assert.Len(t, reader.entryArrays, 4)
+			}
+			_, err = reader.Next()
+			assert.ErrorIs(t, err, io.EOF)
+			log, err := reader.Previous()
+			require.NoError(t, err)
+			message, _ := log.Get("MESSAGE")
+			assert.Equal(t, fmt.Sprintf("message %d", n-1), message)
+
+			require.NoError(t, reader.seekTailEntries(30))
+			expected := []int{}
+			for i := n - 30; i < n; i++ {
+				expected = append(expected, i)
+			}
+			assert.Equal(t, testMessages(expected...), readMessages(t, reader.Next))
+
+			// all entries are read if there are less of them
+			require.NoError(t, reader.seekTailEntries(n+1))
+			assert.Len(t, readMessages(t, reader.Next), n)
+		})
+	}
+}
+
+func TestReaderSeekTailInconsistentHeader(t *testing.T) {
+	path := writeTestJournal(t, WriterConfig{}, 20)
+
+	// tail entry offset pointing to other entry than the last item of the tail entry array
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.NoError(t, err)
+	_, err = file.WriteAt(binary.LittleEndian.AppendUint64(nil, 16), 264)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	reader, err := NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, reader.seekTailEntries(1))
+	assert.Equal(t, testMessages(19), readMessages(t, reader.Next))
+}
@@ -0,0 +1,451 @@
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// This implementation base on https://github.com/systemd/systemd/blob/main/src/libsystemd/sd-journal/journal-verify.c
+
+// CorruptionError describes inconsistency of the journal file found at the given offset
+type CorruptionError struct {
+	Offset uint64
+	Reason string
+}
+
+// Error implements error interface
+func (e *CorruptionError) Error() string {
+	return fmt.Sprintf("%s at %d", e.Reason, e.Offset)
+}
+
+// corrupted returns CorruptionError for the given offset
+func corrupted(offset uint64, format string, args ...any) error {
+	return &CorruptionError{
+		Offset: offset,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+// dataPayloadOffset returns offset of the payload in Data object
+func dataPayloadOffset(compact bool) uint64 {
+	if compact {
+		return OBJECT_HEADER_SIZE + 56
+	}
+	return OBJECT_HEADER_SIZE + 48
+}
+
+// entryItemSize returns size of the single item in Entry object
+func entryItemSize(compact bool) uint64 {
+	if compact {
+		return 4
+	}
+	return 16
+}
+
+// entryArrayItemSize returns size of the single item in EntryArray object
+func entryArrayItemSize(compact bool) uint64 {
+	if compact {
+		return 4
+	}
+	return 8
+}
+
+// checkObject verifies size and flags of the object, so it can be safely decoded
+func checkObject(oh *ObjectHeader, compact bool) error {
+	if oh.objectType != OBJECT_DATA && oh.flags != 0 {
+		return fmt.Errorf("object of type %d has flags (%d) set", oh.objectType, oh.flags)
+	}
+
+	switch oh.objectType {
+	case OBJECT_DATA:
+		compression := oh.flags & (OBJECT_COMPRESSED_XZ | OBJECT_COMPRESSED_LZ4 | OBJECT_COMPRESSED_ZSTD)
+		if oh.flags != compression || compression&(compression-1) != 0 {
+			return fmt.Errorf("data object has invalid flags (%d)", oh.flags)
+		}
+		if oh.size <= dataPayloadOffset(compact) {
+			return fmt.Errorf("data object is too small (%d)", oh.size)
+		}
+	case OBJECT_FIELD:
+		if oh.size <= OBJECT_HEADER_SIZE+24 {
+			return fmt.Errorf("field object is too small (%d)", oh.size)
+		}
+	case OBJECT_ENTRY:
+		itemsSize := int64(oh.size) - OBJECT_HEADER_SIZE - 48
+		if itemsSize <= 0 || uint64(itemsSize)%entryItemSize(compact) != 0 {
+			return fmt.Errorf("entry object has invalid size (%d)", oh.size)
+		}
+	case OBJECT_DATA_HASH_TABLE, OBJECT_FIELD_HASH_TABLE:
+		itemsSize := oh.size - OBJECT_HEADER_SIZE
+		if itemsSize == 0 || itemsSize%HASH_ITEM_SIZE != 0 {
+			return fmt.Errorf("hash table object has invalid size (%d)", oh.size)
+		}
+	case OBJECT_ENTRY_ARRAY:
+		itemsSize := int64(oh.size) - OBJECT_HEADER_SIZE - 8
+		if itemsSize <= 0 || uint64(itemsSize)%entryArrayItemSize(compact) != 0 {
+			return fmt.Errorf("entry array object has invalid size (%d)", oh.size)
+		}
+	case OBJECT_TAG:
+		if oh.size != TAG_OBJECT_SIZE {
+			return fmt.Errorf("tag object has invalid size (%d)", oh.size)
+		}
+	default:
+		return fmt.Errorf("object has invalid type (%d)", oh.objectType)
+	}
+
+	return nil
+}
+
+// objectCounts is number of objects of every type found in the file
+type objectCounts struct {
+	objects         uint64
+	data            uint64
+	fields          uint64
+	entries         uint64
+	entryArrays     uint64
+	tags            uint64
+	dataHashTables  uint64
+	fieldHashTables uint64
+}
+
+// Verify checks structural consistency of the file (journalctl --verify without key)
+// it returns CorruptionError for the first inconsistency found
+func (r *Reader) Verify() error {
+	header := r.getHeader()
+	compact := header.isCompact()
+
+	if header.header_size%8 != 0 || header.header_size < 208 {
+		return corrupted(0, "header size (%d) is invalid", header.header_size)
+	}
+	if header.tail_object_offset%8 != 0 || header.tail_object_offset > header.header_size+header.arena_size {
+		return corrupted(0, "tail object offset (%d) is invalid", header.tail_object_offset)
+	}
+
+	// type of every object, so references can be checked without reading the objects again
+	objects := map[uint64]uint8{}
+	entries := []uint64{}
+	data := []uint64{}
+	counts := objectCounts{}
+
+	lastOffset := uint64(0)
+	for offset := header.header_size; header.tail_object_offset != 0 && offset <= header.tail_object_offset; {
+		oh, err := r.getObject(offset)
+		if err != nil {
+			return corrupted(offset, "cannot read object: %v", err)
+		}
+		if err := checkObject(oh, compact); err != nil {
+			return corrupted(offset, "%v", err)
+		}
+		if offset+oh.size > header.header_size+header.arena_size {
+			return corrupted(offset, "object exceeds arena")
+		}
+
+		objects[offset] = oh.objectType
+		counts.objects++
+
+		switch oh.objectType {
+		case OBJECT_DATA:
+			dataObject, err := oh.Data(compact)
+			if err != nil {
+				return corrupted(offset, "%v", err)
+			}
+			if err := r.verifyData(dataObject); err != nil {
+				return corrupted(offset, "%v", err)
+			}
+			data = append(data, offset)
+			counts.data++
+		case OBJECT_FIELD:
+			field, err := oh.Field()
+			if err != nil {
+				return corrupted(offset, "%v", err)
+			}
+			if header.hash(field.payload) != field.hash {
+				return corrupted(offset, "invalid hash of field object")
+			}
+			counts.fields++
+		case OBJECT_ENTRY:
+			entry, err := oh.Entry(compact)
+			if err != nil {
+				return corrupted(offset, "%v", err)
+			}
+			if entry.seqnum == 0 || entry.realtime == 0 {
+				return corrupted(offset, "entry object has invalid seqnum or timestamp")
+			}
+			entries = append(entries, offset)
+			counts.entries++
+		case OBJECT_DATA_HASH_TABLE:
+			if offset+OBJECT_HEADER_SIZE != header.data_hash_table_offset || oh.payloadSize() != int(header.data_hash_table_size) {
+				return corrupted(offset, "data hash table doesn't match the header")
+			}
+			counts.dataHashTables++
+		case OBJECT_FIELD_HASH_TABLE:
+			if offset+OBJECT_HEADER_SIZE != header.field_hash_table_offset || oh.payloadSize() != int(header.field_hash_table_size) {
+				return corrupted(offset, "field hash table doesn't match the header")
+			}
+			counts.fieldHashTables++
+		case OBJECT_ENTRY_ARRAY:
+			counts.entryArrays++
+		case OBJECT_TAG:
+			if header.compatible_flags&HEADER_COMPATIBLE_SEALED == 0 {
+				return corrupted(offset, "tag object in file without sealing")
+			}
+			counts.tags++
+		}
+
+		lastOffset = offset
+		offset += align64(oh.size)
+	}
+
+	if lastOffset != header.tail_object_offset {
+		return corrupted(lastOffset, "tail object offset (%d) doesn't point to the last object", header.tail_object_offset)
+	}
+
+	if err := verifyCounts(header, counts); err != nil {
+		return err
+	}
+
+	if err := r.verifyEntries(entries, objects); err != nil {
+		return err
+	}
+
+	// main entry array chain has to cover all entries
+	n, err := r.verifyEntryArrayChain(header.entry_array_offset, 0, objects)
+	if err != nil {
+		return err
+	}
+	if n != header.n_entries {
+		return corrupted(header.entry_array_offset, "entry array chain contains %d entries instead of %d", n, header.n_entries)
+	}
+
+	// every data object has its own chain of entries
+	for _, offset := range data {
+		if err := r.verifyDataEntries(offset, objects); err != nil {
+			return err
+		}
+	}
+
+	if err := r.verifyHashTable(header.data_hash_table_offset, header.data_hash_table_size, OBJECT_DATA, counts.data, objects); err != nil {
+		return err
+	}
+
+	return r.verifyHashTable(header.field_hash_table_offset, header.field_hash_table_size, OBJECT_FIELD, counts.fields, objects)
+}
+
+// verifyCounts compares number of found objects with counters stored in the header
+func verifyCounts(header *Header, counts objectCounts) error {
+	if counts.dataHashTables != 1 || counts.fieldHashTables != 1 {
+		return corrupted(0, "file has %d data and %d field hash tables", counts.dataHashTables, counts.fieldHashTables)
+	}
+
+	expected := []struct {
+		name   string
+		header uint64
+		found  uint64
+		since  uint64
+	}{
+		{name: "n_objects", header: header.n_objects, found: counts.objects},
+		{name: "n_entries", header: header.n_entries, found: counts.entries},
+		{name: "n_data", header: header.n_data, found: counts.data, since: 208},
+		{name: "n_fields", header: header.n_fields, found: counts.fields, since: 208},
+		{name: "n_tags", header: header.n_tags, found: counts.tags, since: 224},
+		{name: "n_entry_arrays", header: header.n_entry_arrays, found: counts.entryArrays, since: 224},
+	}
+	for _, counter := range expected {
+		// counter is not available in older headers
+		if header.header_size <= counter.since {
+			continue
+		}
+		if counter.header != counter.found {
+			return corrupted(0, "%s (%d) doesn't match number of objects (%d)", counter.name, counter.header, counter.found)
+		}
+	}
+
+	return nil
+}
+
+// verifyData checks the payload of Data object against its hash
+func (r *Reader) verifyData(data *Data) error {
+	payload, err := data.getPayload(r.MaxDataSize)
+	if err != nil {
+		return fmt.Errorf("cannot decompress data object: %w", err)
+	}
+
+	if bytes.IndexByte(payload, '=') <= 0 {
+		return errors.New("data object has no field name")
+	}
+
+	if r.getHeader().hash(payload) != data.hash {
+		return errors.New("invalid hash of data object")
+	}
+
+	return nil
+}
+
+// verifyEntries checks that entry items point at Data objects
+// xor_hash is not recomputed, the same as journalctl --verify
+func (r *Reader) verifyEntries(entries []uint64, objects map[uint64]uint8) error {
+	for _, offset := range entries {
+		entry, err := r.getEntry(offset)
+		if err != nil {
+			return corrupted(offset, "cannot read entry: %v", err)
+		}
+
+		for _, item := range entry.items() {
+			if objects[item.object_offset] != OBJECT_DATA {
+				return corrupted(offset, "entry item (%d) doesn't point at data object", item.object_offset)
+			}
+		}
+	}
+
+	return nil
+}
+
+// verifyDataEntries checks entries referenced by Data object
+func (r *Reader) verifyDataEntries(offset uint64, objects map[uint64]uint8) error {
+	data, err := r.getData(offset)
+	if err != nil {
+		return corrupted(offset, "cannot read data: %v", err)
+	}
+
+	if data.n_entries == 0 {
+		if data.entry_offset != 0 || data.entry_array_offset != 0 {
+			return corrupted(offset, "data object without entries references entries")
+		}
+		return nil
+	}
+
+	// first entry is stored inline
+	if objects[data.entry_offset] != OBJECT_ENTRY {
+		return corrupted(offset, "data object entry (%d) doesn't point at entry object", data.entry_offset)
+	}
+
+	n, err := r.verifyEntryArrayChain(data.entry_array_offset, data.entry_offset, objects)
+	if err != nil {
+		return err
+	}
+	if n+1 != data.n_entries {
+		return corrupted(offset, "data object references %d entries instead of %d", n+1, data.n_entries)
+	}
+
+	return nil
+}
+
+// verifyEntryArrayChain checks chain of entry arrays starting at the offset
+// items have to point at Entry objects in increasing order, starting after previous
+// it returns number of items in the chain
+func (r *Reader) verifyEntryArrayChain(offset uint64, previous uint64, objects map[uint64]uint8) (uint64, error) {
+	n := uint64(0)
+	lastArray := uint64(0)
+
+	for offset != 0 {
+		// arrays are appended, so increasing offsets guarantee the chain is acyclic
+		if offset <= lastArray {
+			return n, corrupted(offset, "entry array chain is not sorted")
+		}
+		if objects[offset] != OBJECT_ENTRY_ARRAY {
+			return n, corrupted(offset, "entry array chain doesn't point at entry array object")
+		}
+
+		entryArray, err := r.getEntryArray(offset)
+		if err != nil {
+			return n, corrupted(offset, "cannot read entry array: %v", err)
+		}
+
+		items := entryArray.items()
+		count := len(arrayItems(entryArray))
+		for i, item := range items {
+			if i >= count {
+				// array is filled from the beginning, so only zeros can follow
+				if item != 0 {
+					return n, corrupted(offset, "entry array has item after the empty one")
+				}
+				continue
+			}
+			if item <= previous {
+				return n, corrupted(offset, "entry array is not sorted")
+			}
+			if objects[item] != OBJECT_ENTRY {
+				return n, corrupted(offset, "entry array item (%d) doesn't point at entry object", item)
+			}
+			previous = item
+			n++
+		}
+
+		// only the last array in the chain can be partially filled
+		if count < len(items) && entryArray.next_entry_array_offset != 0 {
+			return n, corrupted(offset, "entry array is not full, but it is not the last one")
+		}
+
+		lastArray = offset
+		offset = entryArray.next_entry_array_offset
+	}
+
+	return n, nil
+}
+
+// verifyHashTable checks that every chain of the hash table is acyclic
+// and contains only objects of the given type with hash matching the bucket
+func (r *Reader) verifyHashTable(itemsOffset uint64, size uint64, objectType uint8, n uint64, objects map[uint64]uint8) error {
+	oh, err := r.getObject(itemsOffset - OBJECT_HEADER_SIZE)
+	if err != nil {
+		return corrupted(itemsOffset, "cannot read hash table: %v", err)
+	}
+	hashTable, err := oh.hashTable()
+	if err != nil {
+		return corrupted(itemsOffset, "cannot read hash table: %v", err)
+	}
+
+	buckets := size / HASH_ITEM_SIZE
+	total := uint64(0)
+	for bucket, hashItem := range hashTable.items {
+		last := uint64(0)
+
+		for offset := hashItem.head_hash_offset; offset != 0; {
+			// every object is in exactly one chain, so the cycle results in too many objects
+			total++
+			if total > n {
+				return corrupted(offset, "hash chain is cyclic")
+			}
+			if objects[offset] != objectType {
+				return corrupted(offset, "hash chain doesn't point at object of type %d", objectType)
+			}
+
+			object, err := r.getObject(offset)
+			if err != nil {
+				return corrupted(offset, "cannot read object: %v", err)
+			}
+
+			var hash, next uint64
+			if objectType == OBJECT_DATA {
+				data, err := object.Data(r.isCompact())
+				if err != nil {
+					return corrupted(offset, "cannot read object: %v", err)
+				}
+				hash, next = data.hash, data.next_hash_offset
+			} else {
+				field, err := object.Field()
+				if err != nil {
+					return corrupted(offset, "cannot read object: %v", err)
+				}
+				hash, next = field.hash, field.next_hash_offset
+			}
+
+			if hash%buckets != uint64(bucket) {
+				return corrupted(offset, "object is in the wrong hash bucket")
+			}
+
+			last = offset
+			offset = next
+		}
+
+		if hashItem.tail_hash_offset != last {
+			return corrupted(itemsOffset, "tail of hash chain %d doesn't point at the last object", bucket)
+		}
+	}
+
+	if total != n {
+		return corrupted(itemsOffset, "hash table contains %d objects instead of %d", total, n)
+	}
+
+	return nil
+}
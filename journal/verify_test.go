@@ -0,0 +1,315 @@
+package journal
+
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckObject(t *testing.T) {
+	testCases := []struct {
+		name        string
+		object      ObjectHeader
+		compact     bool
+		expectedErr bool
+	}{
+		{
+			name:   "data",
+			object: ObjectHeader{objectType: OBJECT_DATA, size: 64 + 7},
+		},
+		{
+			name:    "compact data",
+			object:  ObjectHeader{objectType: OBJECT_DATA, flags: OBJECT_COMPRESSED_ZSTD, size: 72 + 7},
+			compact: true,
+		},
+		{
+			name:        "compact data without payload",
+			object:      ObjectHeader{objectType: OBJECT_DATA, size: 72},
+			compact:     true,
+			expectedErr: true,
+		},
+		{
+			name:        "data with two compressions",
+			object:      ObjectHeader{objectType: OBJECT_DATA, flags: OBJECT_COMPRESSED_XZ | OBJECT_COMPRESSED_LZ4, size: 64 + 7},
+			expectedErr: true,
+		},
+		{
+			name:        "data with unknown flag",
+			object:      ObjectHeader{objectType: OBJECT_DATA, flags: 1 << 5, size: 64 + 7},
+			expectedErr: true,
+		},
+		{
+			name:   "field",
+			object: ObjectHeader{objectType: OBJECT_FIELD, size: 40 + 8},
+		},
+		{
+			name:        "field without name",
+			object:      ObjectHeader{objectType: OBJECT_FIELD, size: 40},
+			expectedErr: true,
+		},
+		{
+			name:        "compressed field",
+			object:      ObjectHeader{objectType: OBJECT_FIELD, flags: OBJECT_COMPRESSED_ZSTD, size: 40 + 8},
+			expectedErr: true,
+		},
+		{
+			name:   "entry",
+			object: ObjectHeader{objectType: OBJECT_ENTRY, size: 64 + 2*16},
+		},
+		{
+			name:        "entry with partial item",
+			object:      ObjectHeader{objectType: OBJECT_ENTRY, size: 64 + 4},
+			expectedErr: true,
+		},
+		{
+			name:    "compact entry",
+			object:  ObjectHeader{objectType: OBJECT_ENTRY, size: 64 + 4},
+			compact: true,
+		},
+		{
+			name:        "entry without items",
+			object:      ObjectHeader{objectType: OBJECT_ENTRY, size: 64},
+			expectedErr: true,
+		},
+		{
+			name:        "too small entry",
+			object:      ObjectHeader{objectType: OBJECT_ENTRY, size: 16},
+			expectedErr: true,
+		},
+		{
+			name:   "hash table",
+			object: ObjectHeader{objectType: OBJECT_DATA_HASH_TABLE, size: 16 + 4*16},
+		},
+		{
+			name:        "hash table with partial item",
+			object:      ObjectHeader{objectType: OBJECT_FIELD_HASH_TABLE, size: 16 + 8},
+			expectedErr: true,
+		},
+		{
+			name:   "entry array",
+			object: ObjectHeader{objectType: OBJECT_ENTRY_ARRAY, size: 24 + 4*8},
+		},
+		{
+			name:    "compact entry array",
+			object:  ObjectHeader{objectType: OBJECT_ENTRY_ARRAY, size: 24 + 3*4},
+			compact: true,
+		},
+		{
+			name:        "entry array with partial item",
+			object:      ObjectHeader{objectType: OBJECT_ENTRY_ARRAY, size: 24 + 3*4},
+			expectedErr: true,
+		},
+		{
+			name:   "tag",
+			object: ObjectHeader{objectType: OBJECT_TAG, size: TAG_OBJECT_SIZE},
+		},
+		{
+			name:        "too big tag",
+			object:      ObjectHeader{objectType: OBJECT_TAG, size: TAG_OBJECT_SIZE + 8},
+			expectedErr: true,
+		},
+		{
+			name:        "unused",
+			object:      ObjectHeader{objectType: OBJECT_UNUSED, size: 16},
+			expectedErr: true,
+		},
+		{
+			name:        "unknown type",
+			object:      ObjectHeader{objectType: 42, size: 16},
+			expectedErr: true,
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkObject(&tt.object, tt.compact)
+			if tt.expectedErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// objectOffsets returns offsets of all objects of the given type
+func objectOffsets(t *testing.T, reader *Reader, objectType uint8) []uint64 {
+	offsets := []uint64{}
+	header := reader.getHeader()
+	for offset := header.header_size; offset <= header.tail_object_offset; {
+		oh, err := reader.getObject(offset)
+		require.NoError(t, err)
+		if oh.objectType == objectType {
+			offsets = append(offsets, offset)
+		}
+		offset += align64(oh.size)
+	}
+	return offsets
+}
+
+// putUint64 overwrites the file at the offset with little endian value
+func putUint64(t *testing.T, file *os.File, offset uint64, value uint64) {
+	_, err := file.WriteAt(binary.LittleEndian.AppendUint64(nil, value), int64(offset))
+	require.NoError(t, err)
+}
+
+// getUint64 reads little endian value from the file at the offset
+func getUint64(t *testing.T, file *os.File, offset uint64) uint64 {
+	buffer := make([]byte, 8)
+	_, err := file.ReadAt(buffer, int64(offset))
+	require.NoError(t, err)
+	return binary.LittleEndian.Uint64(buffer)
+}
+
+func TestVerifyCorrupted(t *testing.T) {
+	testCases := []struct {
+		name string
+		// corrupt modifies the file and returns offset expected in CorruptionError
+		corrupt func(t *testing.T, reader *Reader, file *os.File) uint64
+		reason  string
+	}{
+		{
+			name: "n_objects",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				putUint64(t, file, 144, reader.getHeader().n_objects+1)
+				return 0
+			},
+			reason: "n_objects",
+		},
+		{
+			name: "n_entries",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				putUint64(t, file, 152, reader.getHeader().n_entries-1)
+				return 0
+			},
+			reason: "n_entries",
+		},
+		{
+			name: "n_data",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				putUint64(t, file, 208, reader.getHeader().n_data+1)
+				return 0
+			},
+			reason: "n_data",
+		},
+		{
+			name: "n_fields",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				putUint64(t, file, 216, reader.getHeader().n_fields-1)
+				return 0
+			},
+			reason: "n_fields",
+		},
+		{
+			name: "n_entry_arrays",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				putUint64(t, file, 232, reader.getHeader().n_entry_arrays+1)
+				return 0
+			},
+			reason: "n_entry_arrays",
+		},
+		{
+			name: "unaligned tail object offset",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				putUint64(t, file, 136, reader.getHeader().tail_object_offset+4)
+				return 0
+			},
+			reason: "tail object offset",
+		},
+		{
+			name: "tail object offset inside the last object",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				tail := reader.getHeader().tail_object_offset
+				putUint64(t, file, 136, tail+8)
+				return tail
+			},
+			reason: "doesn't point to the last object",
+		},
+		{
+			name: "unsorted entry array",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				// swap the first two items of the first array of the main chain
+				offset := reader.getHeader().entry_array_offset
+				first := getUint64(t, file, offset+OBJECT_HEADER_SIZE+8)
+				second := getUint64(t, file, offset+OBJECT_HEADER_SIZE+16)
+				putUint64(t, file, offset+OBJECT_HEADER_SIZE+8, second)
+				putUint64(t, file, offset+OBJECT_HEADER_SIZE+16, first)
+				return offset
+			},
+			reason: "entry array is not sorted",
+		},
+		{
+			name: "entry item pointing at field",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				entry := objectOffsets(t, reader, OBJECT_ENTRY)[3]
+				putUint64(t, file, entry+OBJECT_HEADER_SIZE+48, objectOffsets(t, reader, OBJECT_FIELD)[0])
+				return entry
+			},
+			reason: "doesn't point at data object",
+		},
+		{
+			name: "cyclic hash chain",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				// next_hash_offset of the data object points at itself
+				data := objectOffsets(t, reader, OBJECT_DATA)[2]
+				putUint64(t, file, data+OBJECT_HEADER_SIZE+8, data)
+				return data
+			},
+			reason: "hash chain is cyclic",
+		},
+		{
+			name: "object in the wrong hash bucket",
+			corrupt: func(t *testing.T, reader *Reader, file *os.File) uint64 {
+				header := reader.getHeader()
+				oh, err := reader.getObject(header.data_hash_table_offset - OBJECT_HEADER_SIZE)
+				require.NoError(t, err)
+				hashTable, err := oh.hashTable()
+				require.NoError(t, err)
+
+				// move chain with single object to the following empty bucket
+				items := hashTable.items
+				for i := 0; i+1 < len(items); i++ {
+					head := items[i].head_hash_offset
+					if head == 0 || head != items[i].tail_hash_offset || items[i+1].head_hash_offset != 0 {
+						continue
+					}
+					bucket := header.data_hash_table_offset + uint64(i)*HASH_ITEM_SIZE
+					putUint64(t, file, bucket, 0)
+					putUint64(t, file, bucket+8, 0)
+					putUint64(t, file, bucket+HASH_ITEM_SIZE, head)
+					putUint64(t, file, bucket+HASH_ITEM_SIZE+8, head)
+					return head
+				}
+				require.Fail(t, "no bucket with single object followed by empty bucket")
+				return 0
+			},
+			reason: "wrong hash bucket",
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestJournal(t, WriterConfig{}, 30)
+
+			reader, err := NewReader(path)
+			require.NoError(t, err)
+			require.NoError(t, reader.Verify())
+
+			file, err := os.OpenFile(path, os.O_RDWR, 0)
+			require.NoError(t, err)
+			expected := tt.corrupt(t, reader, file)
+			require.NoError(t, file.Close())
+			require.NoError(t, reader.Close())
+
+			reader, err = NewReader(path)
+			require.NoError(t, err)
+			defer reader.Close()
+
+			var corruption *CorruptionError
+			require.ErrorAs(t, reader.Verify(), &corruption)
+			assert.Equal(t, expected, corruption.Offset)
+			assert.Contains(t, corruption.Reason, tt.reason)
+		})
+	}
+}
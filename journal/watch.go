@@ -0,0 +1,143 @@
+package journal
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Definitions of the polling intervals used if there is no notification about the change
+// the interval grows from MIN_POLL_INTERVAL to MAX_POLL_INTERVAL while nothing changes
+// with inotify, MAX_POLL_INTERVAL is used only in case some notification is lost
+const (
+	MIN_POLL_INTERVAL = 10 * time.Millisecond
+	MAX_POLL_INTERVAL = time.Second
+)
+
+// adaptivePoll returns polling intervals, which are doubled every time nothing changes
+type adaptivePoll struct {
+	interval time.Duration
+}
+
+// newAdaptivePoll returns adaptivePoll starting with MIN_POLL_INTERVAL
+func newAdaptivePoll() adaptivePoll {
+	return adaptivePoll{interval: MIN_POLL_INTERVAL}
+}
+
+// reset is called once something changed, so the next check is done quickly
+func (p *adaptivePoll) reset() {
+	p.interval = MIN_POLL_INTERVAL
+}
+
+// next returns interval to wait before the next check
+func (p *adaptivePoll) next() time.Duration {
+	interval := p.interval
+	p.interval = min(2*p.interval, MAX_POLL_INTERVAL)
+	return interval
+}
+
+// watcher notifies about changes of journal files in the watched directories using inotify
+// if inotify is not available, nothing is notified and adaptive polling has to be used
+type watcher struct {
+	// changed is notified when a file is created, renamed or removed
+	changed chan struct{}
+
+	// modified is notified when a file is modified and on every notification of changed
+	modified chan struct{}
+
+	// inotify is nil if inotify is not available
+	inotify *os.File
+
+	// some directory cannot be watched, e.g. because its file system doesn't support inotify
+	failed bool
+
+	// mu guards the watches
+	mu sync.Mutex
+
+	// watch descriptors by directory and directories by watch descriptor
+	watches     map[string]int
+	directories map[int]string
+}
+
+// newWatcher returns watcher, which falls back to polling if inotify cannot be initialized
+func newWatcher() *watcher {
+	w := &watcher{
+		changed:     make(chan struct{}, 1),
+		modified:    make(chan struct{}, 1),
+		watches:     map[string]int{},
+		directories: map[int]string{},
+	}
+
+	inotify, err := initInotify()
+	if err == nil {
+		w.inotify = inotify
+		go w.readEvents()
+	}
+
+	return w
+}
+
+// notifying returns true if changes are notified, so polling can be rare
+func (w *watcher) notifying() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.inotify != nil && !w.failed
+}
+
+// pollInterval returns interval to wait for the notification
+func (w *watcher) pollInterval(poll *adaptivePoll) time.Duration {
+	if w.notifying() {
+		return MAX_POLL_INTERVAL
+	}
+	return poll.next()
+}
+
+// watch starts watching the directory, it does nothing if the directory is already watched
+// changes of the directory which cannot be watched are found by polling
+func (w *watcher) watch(directory string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.inotify == nil {
+		return nil
+	}
+	if _, ok := w.watches[directory]; ok {
+		return nil
+	}
+
+	wd, err := addInotifyWatch(w.inotify, directory)
+	if err != nil {
+		w.failed = true
+		return err
+	}
+
+	w.watches[directory] = wd
+	w.directories[wd] = directory
+	return nil
+}
+
+// unwatch forgets the watch descriptor removed by the kernel, e.g. because the directory has been removed
+func (w *watcher) unwatch(wd int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.watches, w.directories[wd])
+	delete(w.directories, wd)
+}
+
+// close stops watching all the directories
+func (w *watcher) close() error {
+	if w.inotify == nil {
+		return nil
+	}
+	return w.inotify.Close()
+}
+
+// notify wakes up the waiting reader, notifications are merged if nobody is waiting
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,73 @@
+package journal
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+)
+
+// events of the watched directory which require scan of the directory
+const INOTIFY_CHANGED = syscall.IN_CREATE | syscall.IN_MOVED_TO | syscall.IN_MOVED_FROM | syscall.IN_DELETE
+
+// initInotify returns non-blocking inotify instance, so reads can be interrupted by closing it
+func initInotify() (*os.File, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_NONBLOCK | syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	return os.NewFile(uintptr(fd), "inotify"), nil
+}
+
+// addInotifyWatch watches the directory for changes and modifications of its files
+func addInotifyWatch(inotify *os.File, directory string) (int, error) {
+	conn, err := inotify.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	wd := 0
+	var watchErr error
+	err = conn.Control(func(fd uintptr) {
+		wd, watchErr = syscall.InotifyAddWatch(int(fd), directory, INOTIFY_CHANGED|syscall.IN_MODIFY)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if watchErr != nil {
+		return 0, os.NewSyscallError("inotify_add_watch", watchErr)
+	}
+
+	return wd, nil
+}
+
+// readEvents reads inotify events and notifies the readers until inotify is closed
+func (w *watcher) readEvents() {
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+
+	for {
+		n, err := w.inotify.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		// struct inotify_event is wd, mask, cookie and length of the name followed by the name
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			wd := int(int32(binary.NativeEndian.Uint32(buffer[offset:])))
+			mask := binary.NativeEndian.Uint32(buffer[offset+4:])
+			nameLength := binary.NativeEndian.Uint32(buffer[offset+12:])
+			offset += syscall.SizeofInotifyEvent + int(nameLength)
+
+			switch {
+			case mask&syscall.IN_IGNORED != 0:
+				w.unwatch(wd)
+				notify(w.changed)
+			// events could be lost, so everything has to be checked again
+			case mask&(INOTIFY_CHANGED|syscall.IN_Q_OVERFLOW) != 0:
+				notify(w.changed)
+				notify(w.modified)
+			case mask&syscall.IN_MODIFY != 0:
+				notify(w.modified)
+			}
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package journal
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoInotify is returned on systems without inotify, so adaptive polling is used
+var errNoInotify = errors.New("inotify is not supported")
+
+// initInotify returns error as inotify is available on linux only
+func initInotify() (*os.File, error) {
+	return nil, errNoInotify
+}
+
+// addInotifyWatch returns error as inotify is available on linux only
+func addInotifyWatch(inotify *os.File, directory string) (int, error) {
+	return 0, errNoInotify
+}
+
+// readEvents does nothing as inotify is available on linux only
+func (w *watcher) readEvents() {}
@@ -0,0 +1,141 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdaptivePoll(t *testing.T) {
+	poll := newAdaptivePoll()
+
+	intervals := []time.Duration{}
+	for i := 0; i < 9; i++ {
+		intervals = append(intervals, poll.next())
+	}
+	assert.Equal(t, []time.Duration{
+		10 * time.Millisecond,
+		20 * time.Millisecond,
+		40 * time.Millisecond,
+		80 * time.Millisecond,
+		160 * time.Millisecond,
+		320 * time.Millisecond,
+		640 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}, intervals)
+
+	poll.reset()
+	assert.Equal(t, MIN_POLL_INTERVAL, poll.next())
+}
+
+// waitNotification returns true if the channel is notified within the timeout
+func waitNotification(ch chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-ch:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	w := newWatcher()
+	defer w.close()
+	if !w.notifying() {
+		t.Skip("inotify is not available")
+	}
+
+	directory := t.TempDir()
+	require.NoError(t, w.watch(directory))
+	require.NoError(t, w.watch(directory))
+	assert.Len(t, w.watches, 1)
+
+	path := filepath.Join(directory, "system.journal")
+	require.NoError(t, os.WriteFile(path, []byte("first"), 0o644))
+	assert.True(t, waitNotification(w.changed, time.Second))
+	assert.True(t, waitNotification(w.modified, time.Second))
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.WriteString("second")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	assert.True(t, waitNotification(w.modified, time.Second))
+	assert.False(t, waitNotification(w.changed, 50*time.Millisecond))
+
+	// watch is removed along with the directory
+	require.NoError(t, os.RemoveAll(directory))
+	assert.True(t, waitNotification(w.changed, time.Second))
+	assert.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return len(w.watches) == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+// logWriterFunc is LogWriter calling the function
+type logWriterFunc func(log Log) error
+
+// Write calls the function
+func (f logWriterFunc) Write(log Log) error {
+	return f(log)
+}
+
+func TestDirectoryReaderFollow(t *testing.T) {
+	directory := t.TempDir()
+	writer, err := NewWriter(filepath.Join(directory, "system.journal"), WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+	writeMessages(t, writer, []uint64{1}, []string{"first"})
+
+	dr := NewDirectoryReader()
+	if !dr.watcher.notifying() {
+		t.Skip("inotify is not available")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go dr.Monitor(ctx, []string{filepath.Join(directory, "*.journal")})
+
+	errStop := errors.New("stop")
+	messages := make(chan string)
+	done := make(chan error)
+	go func() {
+		done <- dr.Read(FilterChain{}, logWriterFunc(func(log Log) error {
+			message, _ := log.Get("MESSAGE")
+			messages <- message
+			if message == "second" {
+				return errStop
+			}
+			return nil
+		}))
+	}()
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, "first", message)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "first entry has not been read")
+	}
+
+	// polling interval grows to MAX_POLL_INTERVAL meanwhile, so only notification delivers the entry quickly
+	time.Sleep(MAX_POLL_INTERVAL + MAX_POLL_INTERVAL/2)
+	written := time.Now()
+	writeMessages(t, writer, []uint64{2}, []string{"second"})
+
+	select {
+	case message := <-messages:
+		assert.Equal(t, "second", message)
+		assert.Less(t, time.Since(written), MAX_POLL_INTERVAL/2)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "second entry has not been read")
+	}
+	assert.ErrorIs(t, <-done, errStop)
+}
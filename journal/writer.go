@@ -0,0 +1,702 @@
+package journal
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// Default number of items in hash tables, the same as used by journald
+	DEFAULT_DATA_HASH_TABLE_SIZE  = 2047
+	DEFAULT_FIELD_HASH_TABLE_SIZE = 333
+
+	// Default minimal size of Data payload to be compressed
+	DEFAULT_COMPRESS_THRESHOLD = 512
+
+	// Minimal number of items in the new entry array
+	MIN_ENTRY_ARRAY_ITEMS = 4
+)
+
+// WriterConfig describes the layout of the file created by Writer
+type WriterConfig struct {
+	// use compact layout (HEADER_INCOMPATIBLE_COMPACT)
+	Compact bool
+	// use siphash24 keyed by file_id (HEADER_INCOMPATIBLE_KEYED_HASH)
+	KeyedHash bool
+	// compress Data payloads using zstd (HEADER_INCOMPATIBLE_COMPRESSED_ZSTD)
+	Compress bool
+	// minimal size of Data payload to be compressed, DEFAULT_COMPRESS_THRESHOLD is used if 0
+	CompressThreshold int
+
+	// machine_id stored in the header
+	MachineID [16]byte
+
+	// size of the header written by older versions of journald, e.g. 240 before 246 or 256 before 252
+	// fields which don't fit are not written, HEADER_MAX_SIZE is used if 0
+	HeaderSize uint64
+
+	// seal the file (HEADER_COMPATIBLE_SEALED), so it can be verified with the key
+	// journald uses the sealing key, but keys of all epochs can be derived from the seed of the verification key
+	// tag is appended whenever entry belongs to the later epoch than the previous one and on Close
+	SealKey *VerificationKey
+}
+
+// entryArrayChain is state of the chain of entry arrays which is appended by Writer
+type entryArrayChain struct {
+	// offset of the first and the last entry array in the chain
+	head uint64
+	tail uint64
+
+	// number of items which fit in the last array and number of used ones
+	tailCapacity uint64
+	tailCount    uint64
+
+	// number of items in the whole chain
+	n uint64
+}
+
+// writerData is state of the Data object written by Writer
+type writerData struct {
+	offset uint64
+	hash   uint64
+
+	// first entry is stored inline, all others in the chain
+	nEntries uint64
+	entries  entryArrayChain
+}
+
+// writerField is state of the Field object written by Writer
+type writerField struct {
+	offset         uint64
+	headDataOffset uint64
+}
+
+// Writer creates journal files
+// it is not safe for concurrent use
+type Writer struct {
+	file   *os.File
+	config WriterConfig
+	header Header
+
+	// offset where the next object is going to be appended
+	end uint64
+
+	// hash tables are kept in memory and written to the file on every change
+	dataHashTable  []HashItem
+	fieldHashTable []HashItem
+	dataDepths     []uint64
+	fieldDepths    []uint64
+
+	// Data and Field objects by their payload
+	data   map[string]*writerData
+	fields map[string]*writerField
+
+	// chain of all entries
+	entries entryArrayChain
+
+	encoder *zstd.Encoder
+
+	// generator of the sealing keys, nil if the file is not sealed
+	// mac covers objects appended since the last tag using the key of the epoch
+	generator *fsprg
+	epoch     uint64
+	mac       hash.Hash
+}
+
+// NewWriter creates new journal file and marks it online
+func NewWriter(filename string, config WriterConfig) (*Writer, error) {
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := newWriterFromPointer(file, config)
+	if err != nil {
+		file.Close()
+		os.Remove(filename)
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// newWriterFromPointer initializes empty file with the header and hash tables
+func newWriterFromPointer(file *os.File, config WriterConfig) (*Writer, error) {
+	if config.CompressThreshold == 0 {
+		config.CompressThreshold = DEFAULT_COMPRESS_THRESHOLD
+	}
+	if config.HeaderSize == 0 {
+		config.HeaderSize = HEADER_MAX_SIZE
+	}
+	if config.HeaderSize%8 != 0 || config.HeaderSize < 208 || config.HeaderSize > HEADER_MAX_SIZE {
+		return nil, fmt.Errorf("header size (%d) is invalid", config.HeaderSize)
+	}
+
+	w := Writer{
+		file:           file,
+		config:         config,
+		end:            config.HeaderSize,
+		dataHashTable:  make([]HashItem, DEFAULT_DATA_HASH_TABLE_SIZE),
+		fieldHashTable: make([]HashItem, DEFAULT_FIELD_HASH_TABLE_SIZE),
+		dataDepths:     make([]uint64, DEFAULT_DATA_HASH_TABLE_SIZE),
+		fieldDepths:    make([]uint64, DEFAULT_FIELD_HASH_TABLE_SIZE),
+		data:           map[string]*writerData{},
+		fields:         map[string]*writerField{},
+	}
+
+	w.header = Header{
+		signature:   [8]byte([]byte("LPKSHHRH")),
+		state:       STATE_ONLINE,
+		machine_id:  config.MachineID,
+		header_size: config.HeaderSize,
+	}
+	// HEADER_COMPATIBLE_TAIL_ENTRY_BOOT_ID is not set, as journalctl --verify
+	// older than 254 refuses files with unknown compatible flags
+
+	if _, err := rand.Read(w.header.file_id[:]); err != nil {
+		return nil, err
+	}
+	// new file starts new sequence
+	w.header.seqnum_id = w.header.file_id
+
+	if config.Compact {
+		w.header.incompatible_flags |= HEADER_INCOMPATIBLE_COMPACT
+	}
+	if config.KeyedHash {
+		w.header.incompatible_flags |= HEADER_INCOMPATIBLE_KEYED_HASH
+	}
+	if config.SealKey != nil {
+		w.header.compatible_flags |= HEADER_COMPATIBLE_SEALED
+	}
+	if config.Compress {
+		w.header.incompatible_flags |= HEADER_INCOMPATIBLE_COMPRESSED_ZSTD
+
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		w.encoder = encoder
+	}
+
+	// hash tables are allocated right after the header
+	offset, err := w.appendObject(OBJECT_DATA_HASH_TABLE, 0, make([]byte, DEFAULT_DATA_HASH_TABLE_SIZE*HASH_ITEM_SIZE))
+	if err != nil {
+		return nil, err
+	}
+	w.header.data_hash_table_offset = offset + OBJECT_HEADER_SIZE
+	w.header.data_hash_table_size = DEFAULT_DATA_HASH_TABLE_SIZE * HASH_ITEM_SIZE
+
+	offset, err = w.appendObject(OBJECT_FIELD_HASH_TABLE, 0, make([]byte, DEFAULT_FIELD_HASH_TABLE_SIZE*HASH_ITEM_SIZE))
+	if err != nil {
+		return nil, err
+	}
+	w.header.field_hash_table_offset = offset + OBJECT_HEADER_SIZE
+	w.header.field_hash_table_size = DEFAULT_FIELD_HASH_TABLE_SIZE * HASH_ITEM_SIZE
+
+	if config.SealKey != nil {
+		w.generator = newFSPRG(config.SealKey.seed)
+		if err := w.appendFirstTag(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+// Close seals the file, marks it offline and closes it
+func (w *Writer) Close() error {
+	var err error
+	if w.generator != nil {
+		err = w.appendTag()
+	}
+
+	w.header.state = STATE_OFFLINE
+	if err == nil {
+		err = w.writeHeader()
+	}
+	if err == nil {
+		err = w.file.Sync()
+	}
+
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+
+	return errors.Join(err, w.file.Close())
+}
+
+// writeHeader writes the header to the file
+// fields after header_size are not written, so they don't overwrite the first object
+func (w *Writer) writeHeader() error {
+	_, err := w.file.WriteAt(w.header.bytes()[:w.header.header_size], 0)
+	return err
+}
+
+// writeUint64 overwrites le64 value stored at the offset
+func (w *Writer) writeUint64(offset uint64, value uint64) error {
+	_, err := w.file.WriteAt(binary.LittleEndian.AppendUint64(nil, value), int64(offset))
+	return err
+}
+
+// writeUint32 overwrites le32 value stored at the offset
+func (w *Writer) writeUint32(offset uint64, value uint32) error {
+	_, err := w.file.WriteAt(binary.LittleEndian.AppendUint32(nil, value), int64(offset))
+	return err
+}
+
+// appendObject appends object with the given payload to the end of the file
+// it returns offset of the object
+func (w *Writer) appendObject(objectType uint8, flags uint8, payload []byte) (uint64, error) {
+	offset := w.end
+	size := OBJECT_HEADER_SIZE + uint64(len(payload))
+
+	// compact files use 32-bit offsets
+	if w.config.Compact && offset+size > math.MaxUint32 {
+		return 0, errors.New("compact journal file cannot be bigger than 4GiB")
+	}
+
+	oh := ObjectHeader{
+		objectType: objectType,
+		flags:      flags,
+		size:       size,
+	}
+
+	// object is padded, so the next one is 64-bit aligned
+	data := make([]byte, 0, align64(size))
+	data = append(data, oh.headerBytes()...)
+	data = append(data, payload...)
+	data = append(data, make([]byte, align64(size)-size)...)
+
+	if _, err := w.file.WriteAt(data, int64(offset)); err != nil {
+		return 0, err
+	}
+
+	w.end = offset + align64(size)
+	w.header.tail_object_offset = offset
+	w.header.arena_size = w.end - w.header.header_size
+	w.header.n_objects++
+
+	// all sealed parts of the object are known once it is appended
+	if w.generator != nil {
+		if w.mac == nil {
+			w.mac = hmac.New(sha256.New, w.generator.key(w.epoch))
+		}
+		oh.setPayload(payload)
+		if err := writeSealedObject(w.mac, &oh, w.config.Compact); err != nil {
+			return 0, err
+		}
+	}
+
+	return offset, nil
+}
+
+// appendFirstTag seals the header and hash tables, which are appended before the header is complete
+func (w *Writer) appendFirstTag() error {
+	w.mac = hmac.New(sha256.New, w.generator.key(w.epoch))
+	writeSealedHeader(w.mac, w.header.bytes())
+
+	// hash tables are covered by their object headers only
+	for _, offset := range []uint64{w.header.data_hash_table_offset, w.header.field_hash_table_offset} {
+		data := make([]byte, OBJECT_HEADER_SIZE)
+		if _, err := w.file.ReadAt(data, int64(offset-OBJECT_HEADER_SIZE)); err != nil {
+			return err
+		}
+		w.mac.Write(data)
+	}
+
+	return w.appendTag()
+}
+
+// appendTag appends tag object sealing all objects appended since the last tag
+func (w *Writer) appendTag() error {
+	payload := binary.LittleEndian.AppendUint64(nil, w.header.n_tags+1)
+	payload = binary.LittleEndian.AppendUint64(payload, w.epoch)
+	payload = append(payload, make([]byte, TAG_LENGTH)...)
+
+	// tag object is sealed as well, except the tag itself
+	offset, err := w.appendObject(OBJECT_TAG, 0, payload)
+	if err != nil {
+		return err
+	}
+	w.header.n_tags++
+
+	tag := w.mac.Sum(nil)
+	w.mac = nil
+	_, err = w.file.WriteAt(tag, int64(offset+OBJECT_HEADER_SIZE+16))
+	return err
+}
+
+// sealEpoch appends tag before the entry if it belongs to the later epoch than the previous ones
+// entries of every epoch are sealed with the key of the epoch, the same way journald does
+func (w *Writer) sealEpoch(realtime uint64) error {
+	if w.generator == nil {
+		return nil
+	}
+
+	epoch := w.config.SealKey.epoch(realtime)
+	if epoch <= w.epoch {
+		return nil
+	}
+
+	if err := w.appendTag(); err != nil {
+		return err
+	}
+	w.epoch = epoch
+	return nil
+}
+
+// writeHashItem writes item of the hash table stored at the given offset
+func (w *Writer) writeHashItem(itemsOffset uint64, bucket uint64, item HashItem) error {
+	data := binary.LittleEndian.AppendUint64(nil, item.head_hash_offset)
+	data = binary.LittleEndian.AppendUint64(data, item.tail_hash_offset)
+	_, err := w.file.WriteAt(data, int64(itemsOffset+bucket*HASH_ITEM_SIZE))
+	return err
+}
+
+// appendEntryArray appends entry array with capacity for the given number of items
+func (w *Writer) appendEntryArray(capacity uint64) (uint64, error) {
+	offset, err := w.appendObject(OBJECT_ENTRY_ARRAY, 0, make([]byte, 8+capacity*entryArrayItemSize(w.config.Compact)))
+	if err != nil {
+		return 0, err
+	}
+	w.header.n_entry_arrays++
+
+	return offset, nil
+}
+
+// appendToChain appends entry offset to the chain of entry arrays
+// new array is allocated if the last one is full
+func (w *Writer) appendToChain(chain *entryArrayChain, entryOffset uint64) error {
+	if chain.tail == 0 || chain.tailCount == chain.tailCapacity {
+		// arrays grow exponentially, so the chain stays short
+		capacity := chain.tailCapacity * 2
+		if chain.n > chain.tailCapacity {
+			capacity = (chain.n + 1) * 2
+		}
+		capacity = max(capacity, MIN_ENTRY_ARRAY_ITEMS)
+
+		offset, err := w.appendEntryArray(capacity)
+		if err != nil {
+			return err
+		}
+
+		// link the new array with the previous one
+		if chain.tail == 0 {
+			chain.head = offset
+		} else if err := w.writeUint64(chain.tail+OBJECT_HEADER_SIZE, offset); err != nil {
+			return err
+		}
+
+		chain.tail = offset
+		chain.tailCapacity = capacity
+		chain.tailCount = 0
+	}
+
+	itemSize := entryArrayItemSize(w.config.Compact)
+	itemOffset := chain.tail + OBJECT_HEADER_SIZE + 8 + chain.tailCount*itemSize
+	var err error
+	if w.config.Compact {
+		err = w.writeUint32(itemOffset, uint32(entryOffset))
+	} else {
+		err = w.writeUint64(itemOffset, entryOffset)
+	}
+	if err != nil {
+		return err
+	}
+
+	chain.tailCount++
+	chain.n++
+
+	return nil
+}
+
+// addField returns Field object for the name, it is created if it doesn't exist yet
+func (w *Writer) addField(name []byte) (*writerField, error) {
+	if field, ok := w.fields[string(name)]; ok {
+		return field, nil
+	}
+
+	hash := w.header.hash(name)
+	payload := binary.LittleEndian.AppendUint64(nil, hash)
+	// next_hash_offset and head_data_offset
+	payload = append(payload, make([]byte, 16)...)
+	payload = append(payload, name...)
+
+	offset, err := w.appendObject(OBJECT_FIELD, 0, payload)
+	if err != nil {
+		return nil, err
+	}
+	w.header.n_fields++
+
+	bucket := hash % DEFAULT_FIELD_HASH_TABLE_SIZE
+	if err := w.linkHashChain(w.fieldHashTable, w.fieldDepths, w.header.field_hash_table_offset, bucket, offset); err != nil {
+		return nil, err
+	}
+	w.header.field_hash_chain_depth = max(w.header.field_hash_chain_depth, w.fieldDepths[bucket])
+
+	field := &writerField{offset: offset}
+	w.fields[string(name)] = field
+
+	return field, nil
+}
+
+// linkHashChain appends object to the chain of the hash table bucket
+// next_hash_offset is stored at the same place for Data and Field objects
+func (w *Writer) linkHashChain(items []HashItem, depths []uint64, itemsOffset uint64, bucket uint64, offset uint64) error {
+	item := items[bucket]
+	if item.tail_hash_offset != 0 {
+		if err := w.writeUint64(item.tail_hash_offset+OBJECT_HEADER_SIZE+8, offset); err != nil {
+			return err
+		}
+	} else {
+		item.head_hash_offset = offset
+	}
+	item.tail_hash_offset = offset
+
+	items[bucket] = item
+	depths[bucket]++
+
+	return w.writeHashItem(itemsOffset, bucket, item)
+}
+
+// addData returns Data object for the payload, it is created if it doesn't exist yet
+func (w *Writer) addData(payload []byte) (*writerData, error) {
+	if data, ok := w.data[string(payload)]; ok {
+		return data, nil
+	}
+
+	name, _, found := bytes.Cut(payload, []byte("="))
+	if !found || len(name) == 0 {
+		return nil, fmt.Errorf("data %q has no field name", payload)
+	}
+
+	field, err := w.addField(name)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := w.header.hash(payload)
+	flags := uint8(0)
+	stored := payload
+	if w.encoder != nil && len(payload) >= w.config.CompressThreshold {
+		compressed := w.encoder.EncodeAll(payload, nil)
+		// keep the payload uncompressed if it doesn't help
+		if len(compressed) < len(payload) {
+			flags = OBJECT_COMPRESSED_ZSTD
+			stored = compressed
+		}
+	}
+
+	object := binary.LittleEndian.AppendUint64(nil, hash)
+	// next_hash_offset
+	object = binary.LittleEndian.AppendUint64(object, 0)
+	// next_field_offset, Data objects are prepended to the list of the field
+	object = binary.LittleEndian.AppendUint64(object, field.headDataOffset)
+	// entry_offset, entry_array_offset and n_entries
+	object = append(object, make([]byte, 24)...)
+	if w.config.Compact {
+		// tail_entry_array_offset and tail_entry_array_n_entries
+		object = append(object, make([]byte, 8)...)
+	}
+	object = append(object, stored...)
+
+	offset, err := w.appendObject(OBJECT_DATA, flags, object)
+	if err != nil {
+		return nil, err
+	}
+	w.header.n_data++
+
+	// head_data_offset
+	field.headDataOffset = offset
+	if err := w.writeUint64(field.offset+OBJECT_HEADER_SIZE+16, offset); err != nil {
+		return nil, err
+	}
+
+	bucket := hash % DEFAULT_DATA_HASH_TABLE_SIZE
+	if err := w.linkHashChain(w.dataHashTable, w.dataDepths, w.header.data_hash_table_offset, bucket, offset); err != nil {
+		return nil, err
+	}
+	w.header.data_hash_chain_depth = max(w.header.data_hash_chain_depth, w.dataDepths[bucket])
+
+	data := &writerData{
+		offset: offset,
+		hash:   hash,
+	}
+	w.data[string(payload)] = data
+
+	return data, nil
+}
+
+// linkDataEntry adds the entry to the list of entries of the Data object
+func (w *Writer) linkDataEntry(data *writerData, entryOffset uint64) error {
+	// Data fields follow the object header
+	base := data.offset + OBJECT_HEADER_SIZE
+
+	if data.nEntries == 0 {
+		// entry_offset
+		if err := w.writeUint64(base+24, entryOffset); err != nil {
+			return err
+		}
+	} else {
+		if err := w.appendToChain(&data.entries, entryOffset); err != nil {
+			return err
+		}
+		// entry_array_offset
+		if err := w.writeUint64(base+32, data.entries.head); err != nil {
+			return err
+		}
+		if w.config.Compact {
+			// tail_entry_array_offset and tail_entry_array_n_entries
+			if err := w.writeUint32(base+48, uint32(data.entries.tail)); err != nil {
+				return err
+			}
+			if err := w.writeUint32(base+52, uint32(data.entries.tailCount)); err != nil {
+				return err
+			}
+		}
+	}
+
+	data.nEntries++
+	// n_entries
+	return w.writeUint64(base+40, data.nEntries)
+}
+
+// writeEntry appends entry consisting of the payloads (FIELD=value)
+// duplicated payloads are stored only once
+func (w *Writer) writeEntry(realtime uint64, monotonic uint64, bootID [16]byte, payloads [][]byte) error {
+	if len(payloads) == 0 {
+		return errors.New("entry has no data")
+	}
+
+	// entries of the previous epoch are sealed before any object of the entry is appended
+	if err := w.sealEpoch(realtime); err != nil {
+		return err
+	}
+
+	items := []*writerData{}
+	xorHash := uint64(0)
+	for _, payload := range payloads {
+		data, err := w.addData(payload)
+		if err != nil {
+			return err
+		}
+		if slices.Contains(items, data) {
+			continue
+		}
+		items = append(items, data)
+
+		// xor_hash uses jenkins lookup3 also for files with keyed hash
+		xorHash ^= jenkinsHash64(payload)
+	}
+
+	// items are sorted by offset, as in the files written by journald
+	slices.SortFunc(items, func(a, b *writerData) int {
+		return cmp.Compare(a.offset, b.offset)
+	})
+
+	seqnum := w.header.tail_entry_seqnum + 1
+
+	entry := binary.LittleEndian.AppendUint64(nil, seqnum)
+	entry = binary.LittleEndian.AppendUint64(entry, realtime)
+	entry = binary.LittleEndian.AppendUint64(entry, monotonic)
+	entry = append(entry, bootID[:]...)
+	entry = binary.LittleEndian.AppendUint64(entry, xorHash)
+	for _, item := range items {
+		if w.config.Compact {
+			entry = binary.LittleEndian.AppendUint32(entry, uint32(item.offset))
+		} else {
+			entry = binary.LittleEndian.AppendUint64(entry, item.offset)
+			entry = binary.LittleEndian.AppendUint64(entry, item.hash)
+		}
+	}
+
+	offset, err := w.appendObject(OBJECT_ENTRY, 0, entry)
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := w.linkDataEntry(item, offset); err != nil {
+			return err
+		}
+	}
+
+	if err := w.appendToChain(&w.entries, offset); err != nil {
+		return err
+	}
+
+	// entry is visible for readers once the header is updated
+	if w.header.n_entries == 0 {
+		w.header.head_entry_seqnum = seqnum
+		w.header.head_entry_realtime = realtime
+	}
+	w.header.n_entries++
+	w.header.tail_entry_seqnum = seqnum
+	w.header.tail_entry_realtime = realtime
+	w.header.tail_entry_monotonic = monotonic
+	w.header.tail_entry_boot_id = bootID
+	w.header.tail_entry_offset = offset
+	w.header.entry_array_offset = w.entries.head
+	w.header.tail_entry_array_offset = uint32(w.entries.tail)
+	w.header.tail_entry_array_n_entries = uint32(w.entries.tailCount)
+
+	return w.writeHeader()
+}
+
+// WriteLog appends Log read by Reader
+// timestamps and boot id are taken from the attributes, other attributes starting with __ are skipped
+func (w *Writer) WriteLog(log Log) error {
+	value, _ := log.Get(ATTRIBUTE_REALTIME_TIMESTAMP)
+	realtime, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", ATTRIBUTE_REALTIME_TIMESTAMP, err)
+	}
+
+	monotonic := uint64(0)
+	if value, ok := log.Get(ATTRIBUTE_MONOTONIC_TIMESTAMP); ok {
+		monotonic, err = strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %w", ATTRIBUTE_MONOTONIC_TIMESTAMP, err)
+		}
+	}
+
+	bootID := [16]byte{}
+	if value, ok := log.Get("_BOOT_ID"); ok {
+		decoded, err := hex.DecodeString(value)
+		if err != nil || len(decoded) != len(bootID) {
+			return fmt.Errorf("invalid _BOOT_ID %q", value)
+		}
+		bootID = [16]byte(decoded)
+	}
+
+	// repeated fields are written as separate data objects
+	payloads := [][]byte{}
+	for _, field := range log.Fields() {
+		if strings.HasPrefix(field.Name, "__") {
+			continue
+		}
+		payload := append([]byte(field.Name+"="), field.Value...)
+		payloads = append(payloads, payload)
+	}
+
+	return w.writeEntry(realtime, monotonic, bootID, payloads)
+}
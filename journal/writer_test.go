@@ -0,0 +1,257 @@
+package journal
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testBootID = [16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+
+// testPayloads returns payloads of the i-th entry written by writeTestJournal
+func testPayloads(i int) [][]byte {
+	payloads := [][]byte{
+		[]byte(fmt.Sprintf("MESSAGE=message %d", i)),
+		[]byte(fmt.Sprintf("_PID=%d", i%7)),
+		[]byte("_SYSTEMD_UNIT=test.service"),
+		[]byte("_BOOT_ID=0102030405060708090a0b0c0d0e0f10"),
+	}
+	if i%10 == 0 {
+		payloads = append(payloads, []byte("BIG="+strings.Repeat("x", 2000)))
+	}
+	return payloads
+}
+
+// writeTestJournal writes journal file with n entries to the temporary directory
+// realtime of the i-th entry is 1700000000000000+i*1000 and monotonic 1000+i
+func writeTestJournal(t *testing.T, config WriterConfig, n int) string {
+	filename := filepath.Join(t.TempDir(), "test.journal")
+
+	writer, err := NewWriter(filename, config)
+	require.NoError(t, err)
+
+	for i := 0; i < n; i++ {
+		err := writer.writeEntry(uint64(1700000000000000)+uint64(i)*1000, uint64(1000+i), testBootID, testPayloads(i))
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	return filename
+}
+
+func TestWriter(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config WriterConfig
+	}{
+		{
+			name:   "regular",
+			config: WriterConfig{},
+		},
+		{
+			name:   "compact",
+			config: WriterConfig{Compact: true},
+		},
+		{
+			name:   "keyed hash",
+			config: WriterConfig{KeyedHash: true},
+		},
+		{
+			name:   "compact keyed hash compressed",
+			config: WriterConfig{Compact: true, KeyedHash: true, Compress: true},
+		},
+		{
+			name:   "header of older version",
+			config: WriterConfig{HeaderSize: 240},
+		},
+	}
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			n := 1000
+			reader, err := NewReader(writeTestJournal(t, tt.config, n))
+			require.NoError(t, err)
+			defer reader.Close()
+
+			header := reader.getHeader()
+			assert.Equal(t, uint8(STATE_OFFLINE), header.state)
+			assert.Equal(t, tt.config.Compact, header.isCompact())
+			assert.Equal(t, tt.config.KeyedHash, header.isKeyedHash())
+			assert.Equal(t, uint64(n), header.n_entries)
+			assert.Equal(t, uint64(1), header.head_entry_seqnum)
+			assert.Equal(t, uint64(n), header.tail_entry_seqnum)
+			assert.Equal(t, uint64(1700000000000000)+uint64(n-1)*1000, header.tail_entry_realtime)
+			require.NoError(t, reader.Verify())
+
+			for i := 0; i < n; i++ {
+				entry, err := reader.getNextEntry()
+				require.NoError(t, err)
+				require.NotNil(t, entry)
+				assert.Equal(t, uint64(i+1), entry.seqnum)
+				assert.Equal(t, uint64(1000+i), entry.monotonic)
+				assert.Equal(t, testBootID, entry.boot_id)
+
+				log, err := reader.readData(entry)
+				require.NoError(t, err)
+				for _, payload := range testPayloads(i) {
+					key, value, _ := strings.Cut(string(payload), "=")
+					assert.Equal(t, [][]byte{[]byte(value)}, log.Values(key))
+				}
+			}
+			entry, err := reader.getNextEntry()
+			require.NoError(t, err)
+			assert.Nil(t, entry)
+
+			data, err := reader.findData([]byte("_PID=3"))
+			require.NoError(t, err)
+			require.NotNil(t, data)
+			entries, err := reader.getDataEntries(data)
+			require.NoError(t, err)
+			// _PID=3 is in every 7th entry starting with the 4th one
+			assert.Len(t, entries, (n+3)/7)
+
+			data, err = reader.findData([]byte("BIG=" + strings.Repeat("x", 2000)))
+			require.NoError(t, err)
+			require.NotNil(t, data)
+			assert.Equal(t, uint64(n/10), data.n_entries)
+			assert.Equal(t, tt.config.Compress, data.flags&OBJECT_COMPRESSED_ZSTD > 0)
+
+			values, err := reader.FieldValues("_PID")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6"}, values)
+		})
+	}
+}
+
+func TestWriterDuplicatedData(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.journal")
+	writer, err := NewWriter(filename, WriterConfig{Compact: true})
+	require.NoError(t, err)
+
+	payloads := [][]byte{[]byte("MESSAGE=first"), []byte("TAG=a"), []byte("MESSAGE=first")}
+	require.NoError(t, writer.writeEntry(1700000000000000, 1, testBootID, payloads))
+	require.NoError(t, writer.Close())
+
+	reader, err := NewReader(filename)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.NoError(t, reader.Verify())
+
+	entry, err := reader.getNextEntry()
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Len(t, entry.items(), 2)
+}
+
+func TestWriterInvalidData(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.journal")
+	writer, err := NewWriter(filename, WriterConfig{})
+	require.NoError(t, err)
+	defer writer.Close()
+
+	assert.Error(t, writer.writeEntry(1, 1, testBootID, [][]byte{[]byte("MESSAGE")}))
+	assert.Error(t, writer.writeEntry(1, 1, testBootID, [][]byte{[]byte("=value")}))
+	assert.Error(t, writer.writeEntry(1, 1, testBootID, [][]byte{}))
+
+	// existing file is never overwritten
+	_, err = NewWriter(filename, WriterConfig{})
+	assert.Error(t, err)
+
+	_, err = NewWriter(filepath.Join(t.TempDir(), "other.journal"), WriterConfig{HeaderSize: 100})
+	assert.Error(t, err)
+}
+
+func TestWriterLog(t *testing.T) {
+	source, err := NewReader(writeTestJournal(t, WriterConfig{Compact: true}, 20))
+	require.NoError(t, err)
+	defer source.Close()
+
+	filename := filepath.Join(t.TempDir(), "copy.journal")
+	writer, err := NewWriter(filename, WriterConfig{})
+	require.NoError(t, err)
+
+	logs := []Log{}
+	for {
+		entry, err := source.getNextEntry()
+		require.NoError(t, err)
+		if entry == nil {
+			break
+		}
+		log, err := source.readData(entry)
+		require.NoError(t, err)
+
+		logs = append(logs, log)
+		require.NoError(t, writer.WriteLog(log))
+	}
+	require.NoError(t, writer.Close())
+
+	reader, err := NewReader(filename)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.NoError(t, reader.Verify())
+
+	for _, log := range logs {
+		entry, err := reader.getNextEntry()
+		require.NoError(t, err)
+		require.NotNil(t, entry)
+
+		read, err := reader.readData(entry)
+		require.NoError(t, err)
+
+		// cursor contains seqnum_id of the file
+		assert.Equal(t, sortedFields(log, ATTRIBUTE_CURSOR), sortedFields(read, ATTRIBUTE_CURSOR))
+	}
+}
+
+func TestWriterLogRepeatedFields(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "test.journal")
+	writer, err := NewWriter(filename, WriterConfig{Compact: true})
+	require.NoError(t, err)
+
+	log := testLog(
+		ATTRIBUTE_REALTIME_TIMESTAMP, "1700000000000000",
+		"MESSAGE", "repeated",
+		"TAG", "a",
+		"TAG", "b",
+		"COREDUMP", "\x7fELF\x00\x01",
+	)
+	require.NoError(t, writer.WriteLog(log))
+	require.NoError(t, writer.Close())
+
+	reader, err := NewReader(filename)
+	require.NoError(t, err)
+	defer reader.Close()
+	require.NoError(t, reader.Verify())
+
+	entry, err := reader.getNextEntry()
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+
+	read, err := reader.readData(entry)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, [][]byte{[]byte("a"), []byte("b")}, read.Values("TAG"))
+	assert.Equal(t, [][]byte{[]byte("\x7fELF\x00\x01")}, read.Values("COREDUMP"))
+}
+
+// sortedFields returns fields of the Log sorted by name and value without the skipped ones
+// writer orders entry items by offset, so the original order of the fields is not preserved
+func sortedFields(log Log, skip ...string) []LogField {
+	fields := []LogField{}
+	for _, field := range log.Fields() {
+		if !slices.Contains(skip, field.Name) {
+			fields = append(fields, field)
+		}
+	}
+	slices.SortFunc(fields, func(a, b LogField) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return bytes.Compare(a.Value, b.Value)
+	})
+	return fields
+}